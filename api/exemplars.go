@@ -0,0 +1,100 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/data/load"
+	"github.com/zeebo/errs"
+)
+
+// exemplar is an exemplar returned by the exemplars endpoint, along with the
+// quantile of its value in the record it came from.
+type exemplar struct {
+	Value     float64 `json:"value"`
+	Id        string  `json:"id"`
+	Quantile  float64 `json:"quantile"`
+	StartTime int64   `json:"start_time"`
+	EndTime   int64   `json:"end_time"`
+}
+
+// serveExemplars serves the exemplars of the records for a metric over a
+// duration that are closest to a quantile as a json list, closest first.
+func (s *Server) serveExemplars(ctx context.Context, w http.ResponseWriter,
+	req *http.Request) (err error) {
+
+	metric := req.FormValue("metric")
+	if metric == "" {
+		return errBadRequest.New("metric required")
+	}
+
+	quantile := getFloat64(req.FormValue("quantile"), 0.99)
+	if quantile < 0 || quantile > 1 {
+		return errBadRequest.New("quantile must be in [0, 1]")
+	}
+	now := getInt64(req.FormValue("now"), time.Now().UnixNano())
+	dur := getDuration(req.FormValue("duration"), 24*time.Hour)
+	results := getInt(req.FormValue("results"), 10)
+	if results < 1 {
+		return errBadRequest.New("results must be positive")
+	}
+	stop_before := now - dur.Nanoseconds()
+
+	var exemplars []exemplar
+	err = s.db.Query(ctx, metric, now, nil,
+		func(ctx context.Context, start, end int64, buf []byte) (
+			bool, error) {
+
+			if end < stop_before {
+				return false, nil
+			}
+
+			var rec data.Record
+			if err := rec.Unmarshal(buf); err != nil {
+				return false, errs.Wrap(err)
+			}
+			if len(rec.Exemplars) == 0 {
+				return true, nil
+			}
+
+			dist, err := load.Load(ctx, rec)
+			if err != nil {
+				return false, errs.Wrap(err)
+			}
+			for _, ex := range rec.Exemplars {
+				exemplars = append(exemplars, exemplar{
+					Value:     ex.Value,
+					Id:        string(ex.Id),
+					Quantile:  dist.CDF(ex.Value),
+					StartTime: rec.StartTime,
+					EndTime:   rec.EndTime,
+				})
+			}
+
+			return true, nil
+		})
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	sort.SliceStable(exemplars, func(i, j int) bool {
+		return math.Abs(exemplars[i].Quantile-quantile) <
+			math.Abs(exemplars[j].Quantile-quantile)
+	})
+	if len(exemplars) > results {
+		exemplars = exemplars[:results]
+	}
+	if exemplars == nil {
+		exemplars = []exemplar{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return errs.Wrap(json.NewEncoder(w).Encode(exemplars))
+}
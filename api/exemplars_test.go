@@ -0,0 +1,92 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/database"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestExemplars(t *testing.T) {
+	params := tdigest.Params{Compression: 5}
+	d, err := params.New()
+	assert.NoError(t, err)
+
+	rec := data.Record{
+		StartTime: 100,
+		EndTime:   200,
+		Kind:      "tdigest",
+	}
+	for i := 0; i < 100; i++ {
+		d.Observe(float64(i))
+		rec.Exemplars = data.MergeExemplars(data.MaxExemplars, rec.Exemplars,
+			[]data.Exemplar{{Value: float64(i), Id: []byte(fmt.Sprint(i))}})
+	}
+	rec.Distribution = d.Marshal(nil)
+	buf, err := rec.Marshal()
+	assert.NoError(t, err)
+
+	s := New(fakeDB{records: [][]byte{buf}}, nil, Options{})
+
+	do := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/exemplars"+query, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	resp := do("?metric=a&quantile=0.99&now=300&duration=1s&results=2")
+	assert.Equal(t, resp.Code, http.StatusOK)
+
+	var got []exemplar
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].Id, "99")
+	assert.Equal(t, got[0].StartTime, int64(100))
+	assert.That(t, got[0].Quantile > 0.9)
+	assert.That(t, got[1].Value < got[0].Value)
+
+	resp = do("?metric=a&quantile=2")
+	assert.Equal(t, resp.Code, http.StatusBadRequest)
+
+	resp = do("?metric=a&results=0")
+	assert.Equal(t, resp.Code, http.StatusBadRequest)
+
+	resp = do("?metric=a&results=-1")
+	assert.Equal(t, resp.Code, http.StatusBadRequest)
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeDB struct {
+	database.DB
+	records [][]byte
+}
+
+func (f fakeDB) Query(ctx context.Context, metric string, end int64,
+	buf []byte, cb database.ResultCallback) error {
+
+	for _, buf := range f.records {
+		var rec data.Record
+		if err := rec.Unmarshal(buf); err != nil {
+			return err
+		}
+		if ok, err := cb(ctx, rec.StartTime, rec.EndTime, buf); !ok ||
+			err != nil {
+			return err
+		}
+	}
+	return nil
+}
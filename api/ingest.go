@@ -0,0 +1,114 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// maxIngestBody is the largest request body accepted by the ingest endpoint.
+const maxIngestBody = 16 << 20
+
+// ingestValue is a single value sent to the ingest endpoint. The metric may
+// have graphite tags, which are sorted like the graphite listener does. The
+// timestamp is in seconds since the unix epoch, and if it is zero, the value
+// is added as if it happened now.
+type ingestValue struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Id        string  `json:"id"`
+	Timestamp float64 `json:"timestamp"`
+}
+
+// ingestResult is the response from the ingest endpoint. Values are late if
+// the server's late policy found their timestamp too old or too far in the
+// future, whether or not the policy added them to a separate metric, and
+// limited if their metric was rejected by the writer's limits.
+type ingestResult struct {
+	Added   int `json:"added"`
+	Late    int `json:"late"`
+	Limited int `json:"limited"`
+}
+
+// serveIngest adds the values in the body to the writer. The body is a
+// stream of json values, each of which is either an object or an array of
+// objects, so that both json arrays and newline delimited json are accepted.
+func (s *Server) serveIngest(ctx context.Context, w http.ResponseWriter,
+	req *http.Request) (err error) {
+
+	if s.opts.Writer == nil {
+		return errNotFound.New("path: %q", req.URL.Path)
+	}
+
+	// decode all of the values first so that a bad request adds nothing.
+	var values []ingestValue
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxIngestBody))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return errBadRequest.Wrap(err)
+		}
+
+		var batch []ingestValue
+		if len(raw) > 0 && raw[0] == '[' {
+			err = json.Unmarshal(raw, &batch)
+		} else {
+			batch = make([]ingestValue, 1)
+			err = json.Unmarshal(raw, &batch[0])
+		}
+		if err != nil {
+			return errBadRequest.Wrap(err)
+		}
+		values = append(values, batch...)
+	}
+
+	for i, value := range values {
+		if value.Metric == "" {
+			return errBadRequest.New("metric required")
+		}
+		metric, err := listener.CanonicalName(value.Metric)
+		if err != nil {
+			return errBadRequest.Wrap(err)
+		}
+		values[i].Metric = metric
+	}
+
+	var result ingestResult
+	for _, value := range values {
+		var id []byte
+		if value.Id != "" {
+			id = []byte(value.Id)
+		}
+
+		var at time.Time
+		if value.Timestamp != 0 {
+			secs, frac := math.Modf(value.Timestamp)
+			at = time.Unix(int64(secs), int64(frac*1e9))
+		}
+
+		// json can not encode NaN or infinities, so no value is invalid.
+		switch s.late.Add(ctx, s.opts.Writer, value.Metric, at,
+			value.Value, id) {
+		case data.NotDropped:
+			result.Added++
+		case data.DropLate:
+			result.Late++
+		case data.DropLimited:
+			result.Limited++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return errs.Wrap(json.NewEncoder(w).Encode(result))
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/assert"
+	"github.com/vivint/rothko/listener"
+)
+
+func TestIngest(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	s := New(nil, nil, Options{
+		Username:  "user",
+		Password:  "pass",
+		Writer:    w,
+		Late:      listener.LateDrop,
+		Tolerance: time.Minute,
+	})
+
+	do := func(method, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/ingest",
+			strings.NewReader(body))
+		if auth {
+			req.SetBasicAuth("user", "pass")
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", `{"metric": "a", "value": 1}`, false)
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	rec = do("POST", `[{"metric": "a", "value": 1, "id": "x"}, `+
+		`{"metric": "a", "value": 5, "timestamp": 1}]`+"\n"+
+		`{"metric": "b", "value": 2}`+"\n"+
+		`{"metric": "a", "value": 3, "timestamp": `+
+		fmt.Sprint(time.Now().Unix())+`}`+"\n", true)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Body.String(),
+		`{"added":3,"late":1,"limited":0}`+"\n")
+
+	// tags are sorted, and invalid tags are rejected.
+	rec = do("POST", `{"metric": "t;b=2;a=1", "value": 1}`, true)
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	rec = do("POST", `{"metric": "t;b", "value": 1}`, true)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = do("POST", `[{"metric": "c", "value": 1}, {"value": 1}]`, true)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = do("POST", `{"metric": "c", "value": "x"}`, true)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = do("GET", "", true)
+	assert.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+
+	rec = do("OPTIONS", "", false)
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	type result struct {
+		obs   int64
+		minId string
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{rec.Observations, string(rec.MinId)}
+			return true
+		})
+
+	assert.DeepEqual(t, got, map[string]result{
+		"a":         {2, "x"},
+		"b":         {1, ""},
+		"t;a=1;b=2": {1, ""},
+	})
+}
+
+func TestIngestLimited(t *testing.T) {
+	w := data.NewWriterWithOptions(fakeParams{}, data.WriterOptions{
+		Limits: data.Limits{Global: 1},
+	})
+	s := New(nil, nil, Options{Writer: w})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/api/ingest",
+		strings.NewReader(`[{"metric": "a", "value": 1}, `+
+			`{"metric": "b", "value": 1}, `+
+			`{"metric": "b", "value": 2, "timestamp": 1}]`)))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Body.String(),
+		`{"added":1,"late":0,"limited":2}`+"\n")
+}
+
+func TestIngestAccept(t *testing.T) {
+	w := data.NewWriter(fakeParams{})
+	s := New(nil, nil, Options{Writer: w})
+
+	// by default, timestamps are ignored.
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/api/ingest",
+		strings.NewReader(`[{"metric": "a", "value": 1, "timestamp": 1}, `+
+			`{"metric": "a", "value": 2, "timestamp": 4e9}]`)))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Body.String(),
+		`{"added":2,"late":0,"limited":0}`+"\n")
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeParams struct{ dist.Params }
+
+func (fakeParams) Kind() string            { return "fake" }
+func (fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
+
+type fakeDist struct{ dist.Dist }
+
+func (fakeDist) Kind() string            { return "fake" }
+func (fakeDist) Observe(val float64)     {}
+func (fakeDist) Marshal(x []byte) []byte { return x }
+func (fakeDist) Merge(dist.Dist) error   { return nil }
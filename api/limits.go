@@ -0,0 +1,54 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/zeebo/errs"
+)
+
+// limitPrefix is a prefix returned by the limits endpoint.
+type limitPrefix struct {
+	Prefix   string `json:"prefix"`
+	Metrics  int64  `json:"metrics"`
+	Rejected int64  `json:"rejected"`
+}
+
+// limitResult is the response from the limits endpoint.
+type limitResult struct {
+	Metrics  int64         `json:"metrics"`
+	Prefixes []limitPrefix `json:"prefixes"`
+}
+
+// serveLimits serves how many metrics the writer's limits have admitted and
+// the prefixes with the most rejected values as json.
+func (s *Server) serveLimits(ctx context.Context, w http.ResponseWriter,
+	req *http.Request) (err error) {
+
+	if s.opts.Writer == nil {
+		return errNotFound.New("path: %q", req.URL.Path)
+	}
+
+	results := getInt(req.FormValue("results"), 10)
+	if results < 1 {
+		return errBadRequest.New("results must be positive")
+	}
+	metrics, prefixes, ok := s.opts.Writer.LimitStats(results)
+	if !ok {
+		return errNotFound.New("no limits configured")
+	}
+
+	result := limitResult{
+		Metrics:  metrics,
+		Prefixes: make([]limitPrefix, 0, len(prefixes)),
+	}
+	for _, prefix := range prefixes {
+		result.Prefixes = append(result.Prefixes, limitPrefix(prefix))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return errs.Wrap(json.NewEncoder(w).Encode(result))
+}
@@ -0,0 +1,46 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+
+	do := func(w *data.Writer, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/limits"+query, nil)
+		rec := httptest.NewRecorder()
+		New(nil, nil, Options{Writer: w}).ServeHTTP(rec, req)
+		return rec
+	}
+
+	w := data.NewWriterWithOptions(fakeParams{}, data.WriterOptions{
+		Limits: data.Limits{PerPrefix: 1},
+	})
+	w.Add(ctx, "a.1", 1, nil)
+	w.Add(ctx, "a.2", 1, nil)
+	w.Add(ctx, "b.1", 1, nil)
+
+	rec := do(w, "?results=1")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Body.String(),
+		`{"metrics":2,"prefixes":[{"prefix":"a","metrics":1,"rejected":1}]}`+
+			"\n")
+
+	rec = do(w, "?results=0")
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = do(w, "?results=-1")
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = do(data.NewWriter(fakeParams{}), "")
+	assert.Equal(t, rec.Code, http.StatusNotFound)
+}
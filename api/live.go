@@ -0,0 +1,55 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+// serveLive serves the record for a metric in one of the writer's rolling
+// windows as json, so that the frontend can show a distribution before it is
+// flushed to the database. The window defaults to the first one configured.
+// The record is null if the metric has no values in the window.
+func (s *Server) serveLive(ctx context.Context, w http.ResponseWriter,
+	req *http.Request) (err error) {
+
+	if s.opts.Writer == nil || len(s.opts.Writer.Windows()) == 0 {
+		return errNotFound.New("path: %q", req.URL.Path)
+	}
+
+	metric := req.FormValue("metric")
+	if metric == "" {
+		return errBadRequest.New("metric required")
+	}
+
+	window := getDuration(req.FormValue("window"),
+		s.opts.Writer.Windows()[0])
+	now := time.Now().UnixNano()
+
+	rec, ok, err := s.opts.Writer.Load(ctx, metric, window)
+	if err != nil {
+		return errNotFound.Wrap(err)
+	}
+
+	var record []byte
+	if ok {
+		record, err = rec.Marshal()
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	type D = map[string]interface{}
+	return errs.Wrap(json.NewEncoder(w).Encode(D{
+		"metric": metric,
+		"window": window.Nanoseconds(),
+		"now":    now,
+		"record": record,
+	}))
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestLive(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWindowedWriter(fakeParams{}, time.Minute, time.Hour)
+	s := New(nil, nil, Options{Writer: w})
+
+	do := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/live"+query, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	w.Add(ctx, "a", 1, nil)
+	w.Add(ctx, "a", 2, nil)
+
+	var resp struct {
+		Metric string
+		Window int64
+		Record []byte
+	}
+
+	rec := do("?metric=a&window=1h")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, resp.Metric, "a")
+	assert.Equal(t, resp.Window, int64(time.Hour))
+
+	var got data.Record
+	assert.NoError(t, got.Unmarshal(resp.Record))
+	assert.Equal(t, got.Observations, int64(2))
+	assert.Equal(t, got.Max, float64(2))
+
+	rec = do("?metric=b")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, resp.Window, int64(time.Minute))
+	assert.That(t, resp.Record == nil)
+
+	rec = do("?metric=a&window=1s")
+	assert.Equal(t, rec.Code, http.StatusNotFound)
+
+	rec = do("")
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+}
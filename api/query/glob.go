@@ -50,3 +50,50 @@ func glob(pattern, name string) bool {
 
 	return true
 }
+
+// Match matches a pattern with the entire name, ignoring case. In the
+// pattern, "?" matches any single character other than a dot, "*" matches any
+// run of characters other than a dot, and "**" matches any run of characters,
+// so "app.*" matches "app.latency" but not "app.latency.p99".
+func Match(pattern, name string) bool {
+	return matchSep(pattern, name, '.')
+}
+
+// matchSep is like Match, except that the separator is the character that "?"
+// and "*" do not match. If it is zero, they match any character, and "**"
+// is the same as "*".
+func matchSep(pattern, name string, sep byte) bool {
+	// match[nx] is true if the pattern so far matches name[:nx].
+	match := make([]bool, len(name)+1)
+	match[0] = true
+
+	for px := 0; px < len(pattern); px++ {
+		switch c := pattern[px]; {
+		case c == '*' && px+1 < len(pattern) && pattern[px+1] == '*':
+			px++
+			for nx := 1; nx <= len(name); nx++ {
+				match[nx] = match[nx] || match[nx-1]
+			}
+
+		case c == '*':
+			for nx := 1; nx <= len(name); nx++ {
+				if sep == 0 || name[nx-1] != sep {
+					match[nx] = match[nx] || match[nx-1]
+				}
+			}
+
+		default:
+			for nx := len(name); nx > 0; nx-- {
+				n := name[nx-1]
+				if 'A' <= n && n <= 'Z' {
+					n += 'a' - 'A'
+				}
+				match[nx] = match[nx-1] &&
+					(n == c || (c == '?' && (sep == 0 || n != sep)))
+			}
+			match[0] = false
+		}
+	}
+
+	return match[len(name)]
+}
@@ -19,6 +19,27 @@ func TestGlob(t *testing.T) {
 	assert.That(t, glob("abc", "ABC"))
 }
 
+func TestMatch(t *testing.T) {
+	assert.That(t, Match("app.latency", "app.latency"))
+	assert.That(t, Match("app.latency", "APP.Latency"))
+	assert.That(t, !Match("app.latency", "app.latency.p99"))
+	assert.That(t, !Match("app.lat", "app.latency"))
+	assert.That(t, !Match("pp.latency", "app.latency"))
+
+	assert.That(t, Match("app.*", "app.latency"))
+	assert.That(t, Match("app.*", "app."))
+	assert.That(t, !Match("app.*", "app.latency.p99"))
+	assert.That(t, Match("app.*.p99", "app.latency.p99"))
+	assert.That(t, Match("*.latency", "app.latency"))
+
+	assert.That(t, Match("app.**", "app.latency.p99"))
+	assert.That(t, Match("**.p99", "app.latency.p99"))
+	assert.That(t, !Match("**.p99", "app.latency.p999"))
+
+	assert.That(t, Match("app.l?tency", "app.latency"))
+	assert.That(t, !Match("app?latency", "app.latency"))
+}
+
 func BenchmarkGlob(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		glob("asdf*asdf*asdf", "asdf1234asdf1234asdf1234")
@@ -9,26 +9,40 @@ import (
 // Search represents a metric search.
 type Search struct {
 	specs   []spec
+	tags    []tagSpec
 	matched []string
 }
 
-// New constructs a metric searcher from the query string.
+// New constructs a metric searcher from the query string. Parts of the query
+// like "key=value" filter on graphite style tags in the metric name, matching
+// the value exactly unless it has a "*" or "?".
 func New(query string, capacity int) *Search {
-	parts := strings.Fields(query)
-	specs := make([]spec, len(parts))
-	for i, part := range parts {
-		specs[i] = newSpec(part)
+	var specs []spec
+	var tags []tagSpec
+	for _, part := range strings.Fields(query) {
+		if strings.IndexByte(part, '=') >= 0 {
+			tags = append(tags, newTagSpec(part))
+		} else {
+			specs = append(specs, newSpec(part))
+		}
 	}
 	return &Search{
 		specs:   specs,
+		tags:    tags,
 		matched: make([]string, 0, capacity),
 	}
 }
 
 // Match checks if the Search matches the metric.
 func (s *Search) Match(metric string) bool {
+	name, tags := splitTags(metric)
 	for _, spec := range s.specs {
-		if !spec.Match(metric) {
+		if !spec.Match(name) {
+			return false
+		}
+	}
+	for _, tag := range s.tags {
+		if !tag.Match(tags) {
 			return false
 		}
 	}
@@ -0,0 +1,80 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package query
+
+import (
+	"strings"
+)
+
+// tagSpec is a type that matches a metric with graphite style tags, like
+// "name;host=web1;dc=east". It matches if any tag has the key and the value,
+// ignoring case. If the value has a "*" or "?", it is a pattern that must
+// match the whole tag value, where "*" matches any run of characters and "?"
+// matches any single character. Tag values are not dot separated, so both
+// match dots too. For example, host=web1 and host=web* will match the above
+// metric, but host=web will not. An empty value matches any value for the
+// key.
+type tagSpec struct {
+	key   string
+	value string
+	glob  bool
+}
+
+// newTagSpec constructs a tagSpec from a string like "key=value".
+func newTagSpec(s string) tagSpec {
+	index := strings.IndexByte(s, '=')
+	if index == -1 {
+		return tagSpec{key: s}
+	}
+
+	value := strings.ToLower(s[index+1:])
+	return tagSpec{
+		key:   s[:index],
+		value: value,
+		glob:  strings.ContainsAny(value, "*?"),
+	}
+}
+
+// Match returns true if the tags match the tagSpec. The tags are expected to
+// be as returned by splitTags.
+func (t tagSpec) Match(tags string) bool {
+	for len(tags) > 0 {
+		var tag string
+		tag, tags = splitTag(tags[1:])
+
+		index := strings.IndexByte(tag, '=')
+		if index == -1 || !strings.EqualFold(tag[:index], t.key) {
+			continue
+		}
+
+		value := tag[index+1:]
+		switch {
+		case t.value == "":
+			return true
+		case t.glob && matchSep(t.value, value, 0):
+			return true
+		case !t.glob && strings.EqualFold(t.value, value):
+			return true
+		}
+	}
+	return false
+}
+
+// splitTag pulls the first semicolon separated tag off of the tags.
+func splitTag(tags string) (tag, rest string) {
+	index := strings.IndexByte(tags, ';')
+	if index == -1 {
+		return tags, ""
+	}
+	return tags[:index], tags[index:]
+}
+
+// splitTags splits the metric into the name and the tags, where the tags
+// include the leading semicolon.
+func splitTags(metric string) (name, tags string) {
+	index := strings.IndexByte(metric, ';')
+	if index == -1 {
+		return metric, ""
+	}
+	return metric[:index], metric[index:]
+}
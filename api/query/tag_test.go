@@ -0,0 +1,41 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestTagSpec(t *testing.T) {
+	match := func(spec, metric string) bool {
+		_, tags := splitTags(metric)
+		return newTagSpec(spec).Match(tags)
+	}
+
+	assert.That(t, match("host=web1", "cpu.load;dc=east;host=web1"))
+	assert.That(t, !match("host=web", "cpu.load;dc=east;host=web1"))
+	assert.That(t, match("HOST=WEB1", "cpu.load;dc=east;host=web1"))
+	assert.That(t, match("host=web*", "cpu.load;dc=east;host=web1"))
+	assert.That(t, match("host=web?", "cpu.load;dc=east;host=web1"))
+	assert.That(t, !match("host=web?", "cpu.load;dc=east;host=web12"))
+	assert.That(t, match("host=web*", "cpu.load;host=web1.example.com"))
+	assert.That(t, !match("host=web1", "cpu.load;host=web1.example.com"))
+	assert.That(t, match("host=web1?example.com",
+		"cpu.load;host=web1.example.com"))
+	assert.That(t, match("dc=e*t", "cpu.load;dc=east;host=web1"))
+	assert.That(t, match("dc=", "cpu.load;dc=east;host=web1"))
+	assert.That(t, !match("dc=west", "cpu.load;dc=east;host=web1"))
+	assert.That(t, !match("rack=1", "cpu.load;dc=east;host=web1"))
+	assert.That(t, !match("host=web1", "cpu.load"))
+}
+
+func TestSearch(t *testing.T) {
+	search := New("load dc=east", 10)
+
+	assert.That(t, search.Match("cpu.load;dc=east;host=web1"))
+	assert.That(t, !search.Match("cpu.load;dc=west;host=web1"))
+	assert.That(t, !search.Match("cpu.load"))
+	assert.That(t, !search.Match("cpu.idle;dc=east;host=load"))
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/vivint/rothko/draw/colors"
 	"github.com/vivint/rothko/draw/graph"
 	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
 	"github.com/vivint/rothko/merge"
 	"github.com/zeebo/errs"
 )
@@ -37,6 +38,19 @@ type Options struct {
 	// basic auth will be required.
 	Username string
 	Password string
+
+	// Writer receives the values posted to the ingest endpoint, and its
+	// rolling windows are served by the live endpoint. If unset, neither
+	// endpoint is served.
+	Writer *data.Writer
+
+	// Late, Tolerance and LateSuffix control what happens to values posted
+	// to the ingest endpoint with timestamps, like the graphite listener
+	// options of the same names. Late defaults to listener.LateAccept, which
+	// ignores timestamps.
+	Late       listener.LatePolicy
+	Tolerance  time.Duration
+	LateSuffix string
 }
 
 // Server is an http.Handler that can serve responses for a frontend.
@@ -44,6 +58,7 @@ type Server struct {
 	db     database.DB
 	static http.Handler
 	opts   Options
+	late   *listener.Late
 
 	username_hash [sha256.Size]byte
 	password_hash [sha256.Size]byte
@@ -61,6 +76,12 @@ func New(db database.DB, static http.Handler, opts Options) *Server {
 		db:     db,
 		static: static,
 		opts:   opts,
+		late: &listener.Late{
+			Policy:    opts.Late,
+			Tolerance: opts.Tolerance,
+			Suffix:    opts.LateSuffix,
+			Name:      "api_ingest",
+		},
 
 		username_hash: sha256.Sum256([]byte(opts.Username)),
 		password_hash: sha256.Sum256([]byte(opts.Password)),
@@ -115,6 +136,16 @@ func (s *Server) validAuth(ctx context.Context,
 func (s *Server) serveHTTP(ctx context.Context, w http.ResponseWriter,
 	req *http.Request) (err error) {
 
+	// cors preflight requests never include credentials, so they have to be
+	// answered before checking for them.
+	if req.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers",
+			"Authorization, Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
 	if s.opts.Username != "" {
 		username, password, ok := req.BasicAuth()
 		if !ok || !s.validAuth(ctx, username, password) {
@@ -123,6 +154,13 @@ func (s *Server) serveHTTP(ctx context.Context, w http.ResponseWriter,
 		}
 	}
 
+	if req.URL.Path == "/api/ingest" {
+		if req.Method != "POST" {
+			return errMethodNotAllowed.New("%s", req.Method)
+		}
+		return s.serveIngest(ctx, w, req)
+	}
+
 	if req.Method != "GET" {
 		return errMethodNotAllowed.New("%s", req.Method)
 	}
@@ -134,6 +172,15 @@ func (s *Server) serveHTTP(ctx context.Context, w http.ResponseWriter,
 	case "/api/query":
 		return s.serveQuery(ctx, w, req)
 
+	case "/api/live":
+		return s.serveLive(ctx, w, req)
+
+	case "/api/exemplars":
+		return s.serveExemplars(ctx, w, req)
+
+	case "/api/limits":
+		return s.serveLimits(ctx, w, req)
+
 	case "/api/nonce":
 		return s.serveNonce(ctx, w, req)
 
@@ -177,6 +224,11 @@ func (s *Server) serveRender(ctx context.Context, w http.ResponseWriter,
 		Padding:  padding,
 	}
 
+	// keep track of the sums of every record so that we can report the
+	// exact mean and standard deviation over the whole duration.
+	var total data.Record
+	has_sums := true
+
 	merger := merge.NewMerger(merge.MergerOptions{
 		Samples:  samples,
 		Now:      now,
@@ -212,6 +264,11 @@ func (s *Server) serveRender(ctx context.Context, w http.ResponseWriter,
 				merger.SetWidth(measured.Width)
 			}
 
+			total.Observations += rec.Observations
+			total.Sum += rec.Sum
+			total.SumSquares += rec.SumSquares
+			has_sums = has_sums && rec.HasSums()
+
 			// push in the record
 			if err := merger.Push(ctx, rec); err != nil {
 				return false, errs.Wrap(err)
@@ -237,7 +294,7 @@ func (s *Server) serveRender(ctx context.Context, w http.ResponseWriter,
 	if req.Header.Get("Accept") == "application/json" {
 		w.Header().Set("Content-Type", "application/json")
 		type D = map[string]interface{}
-		return errs.Wrap(json.NewEncoder(w).Encode(D{
+		resp := D{
 			"metric":   metric,
 			"columns":  cols,
 			"earliest": earliest,
@@ -246,7 +303,12 @@ func (s *Server) serveRender(ctx context.Context, w http.ResponseWriter,
 			"width":    width,
 			"height":   height,
 			"padding":  padding,
-		}))
+		}
+		if has_sums && total.Observations > 0 {
+			resp["mean"] = total.Mean()
+			resp["stddev"] = total.Stddev()
+		}
+		return errs.Wrap(json.NewEncoder(w).Encode(resp))
 	}
 
 	// if we never got an earliest, we need to measure without it to get the
@@ -0,0 +1,223 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/data/frame"
+	"github.com/vivint/rothko/database"
+	"github.com/vivint/rothko/external"
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors from the checkpoint package.
+var Error = errs.Class("checkpoint")
+
+// header starts every checkpoint file, and includes a version number.
+var header = []byte("rothko checkpoint 1\n")
+
+// Options controls the options to the Checkpointer.
+type Options struct {
+	// Path is the file the checkpoint is written to.
+	Path string
+
+	// How often to checkpoint. Defaults to 1 minute.
+	Interval time.Duration
+}
+
+// Checkpointer is a worker that periodically saves the current records of a
+// Writer to a file, and can replay them back into a Writer.
+type Checkpointer struct {
+	opts Options
+}
+
+// New constructs a Checkpointer with the given options.
+func New(opts Options) *Checkpointer {
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+
+	return &Checkpointer{
+		opts: opts,
+	}
+}
+
+// Run checkpoints periodically, until the context is canceled. It writes one
+// last checkpoint when the context is canceled, so that a clean shutdown does
+// not lose the values added since the previous one.
+func (c *Checkpointer) Run(ctx context.Context, w *data.Writer) (err error) {
+	done := ctx.Done()
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			external.Infow("performing last checkpoint")
+			c.checkpoint(context.Background(), w)
+			return nil
+
+		case <-ticker.C:
+			c.checkpoint(ctx, w)
+		}
+	}
+}
+
+// checkpoint calls Checkpoint, logging any error.
+func (c *Checkpointer) checkpoint(ctx context.Context, w *data.Writer) {
+	if err := c.Checkpoint(ctx, w); err != nil {
+		external.Errorw("checkpoint failed",
+			"path", c.opts.Path,
+			"err", err.Error(),
+		)
+	}
+}
+
+// Checkpoint writes the records in the Writer's flush window, the ones that
+// would be returned from Capture, to the file, atomically replacing any
+// previous checkpoint.
+func (c *Checkpointer) Checkpoint(ctx context.Context,
+	w *data.Writer) (err error) {
+
+	now := time.Now()
+	records := 0
+
+	buf := append([]byte(nil), header...)
+	iter_err := w.Iterate(ctx, 0, func(ctx context.Context, metric string,
+		rec data.Record) bool {
+
+		buf, err = frame.Append(buf, metric, rec)
+		records++
+		return err == nil
+	})
+	if iter_err != nil {
+		return Error.Wrap(iter_err)
+	}
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	// write to a temporary file in the same directory and rename it over
+	// the checkpoint so that a crash never leaves a partial checkpoint.
+	dir, name := filepath.Dir(c.opts.Path), filepath.Base(c.opts.Path)
+	fh, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	defer func() {
+		if err != nil {
+			fh.Close()
+			os.Remove(fh.Name())
+		}
+	}()
+
+	if _, err := fh.Write(buf); err != nil {
+		return Error.Wrap(err)
+	}
+	if err := fh.Sync(); err != nil {
+		return Error.Wrap(err)
+	}
+	if err := fh.Close(); err != nil {
+		return Error.Wrap(err)
+	}
+	if err := os.Rename(fh.Name(), c.opts.Path); err != nil {
+		return Error.Wrap(err)
+	}
+
+	external.Observe("checkpoint_duration", time.Since(now).Seconds())
+	external.Observe("checkpoint_records", float64(records))
+	return nil
+}
+
+// Replay merges the records in the checkpoint file into the Writer, if it
+// exists. Records that end before the latest record for the metric in the
+// source were already written, and are skipped. It should be called before
+// any values are added to the Writer, so that the restored records keep
+// their start time. If any record can not be merged into the Writer, for
+// example because the distribution kind changed, the rest are still
+// restored and an error is returned.
+func (c *Checkpointer) Replay(ctx context.Context, w *data.Writer,
+	src database.Source) (err error) {
+
+	buf, err := ioutil.ReadFile(c.opts.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return Error.Wrap(err)
+	}
+	if !bytes.HasPrefix(buf, header) {
+		return Error.New("invalid checkpoint header")
+	}
+	buf = buf[len(header):]
+
+	type entry struct {
+		metric string
+		rec    data.Record
+	}
+
+	// parse all of the records first so that a corrupt checkpoint restores
+	// nothing, and drop any that were already written.
+	var entries []entry
+	var start int64
+	for len(buf) > 0 {
+		var metric string
+		var rec_data []byte
+		metric, rec_data, buf, err = frame.Next(buf)
+		if err != nil {
+			return Error.Wrap(err)
+		}
+		var rec data.Record
+		if err := rec.Unmarshal(rec_data); err != nil {
+			return Error.Wrap(err)
+		}
+
+		_, end, _, err := src.QueryLatest(ctx, metric, nil)
+		if err == nil && end > rec.StartTime {
+			continue
+		}
+
+		if len(entries) == 0 || rec.StartTime < start {
+			start = rec.StartTime
+		}
+		entries = append(entries, entry{metric: metric, rec: rec})
+	}
+
+	if len(entries) > 0 {
+		w.SetStart(time.Unix(0, start))
+	}
+
+	restored, failed := 0, 0
+	var first error
+	for _, entry := range entries {
+		if err := w.AddRecord(ctx, entry.metric, entry.rec); err != nil {
+			external.Errorw("unable to restore checkpoint record",
+				"metric", entry.metric,
+				"err", err.Error(),
+			)
+			if first == nil {
+				first = err
+			}
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	external.Infow("replayed checkpoint",
+		"path", c.opts.Path,
+		"restored", restored,
+		"failed", failed,
+	)
+
+	if first != nil {
+		return Error.New("unable to restore %d records: %v", failed, first)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package checkpoint
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/database"
+	"github.com/vivint/rothko/dist/ddsketch"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	params := tdigest.Params{Compression: 5}
+
+	dir, err := ioutil.TempDir("", "checkpoint-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := New(Options{Path: filepath.Join(dir, "checkpoint")})
+
+	// replaying a missing checkpoint does nothing
+	w := data.NewWriter(params)
+	assert.NoError(t, c.Replay(ctx, w, fakeSource{}))
+
+	start := time.Now().Add(-time.Minute)
+	assert.That(t, w.SetStart(start))
+	w.Add(ctx, "foo", 1, nil)
+	w.Add(ctx, "foo", 3, nil)
+	w.Add(ctx, "bar", 2, nil)
+	w.Add(ctx, "dumped", 2, nil)
+	assert.NoError(t, c.Checkpoint(ctx, w))
+
+	// a fresh writer gets the records back, except for ones that the source
+	// already has.
+	w = data.NewWriter(params)
+	assert.NoError(t, c.Replay(ctx, w, fakeSource{
+		"dumped": start.Add(time.Second).UnixNano(),
+	}))
+
+	got := make(map[string]data.Record)
+	w.Capture(ctx, func(ctx context.Context, metric string,
+		rec data.Record) bool {
+
+		got[metric] = rec
+		return true
+	})
+
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got["foo"].StartTime, start.UnixNano())
+	assert.Equal(t, got["foo"].Observations, int64(2))
+	assert.Equal(t, got["foo"].Min, 1.0)
+	assert.Equal(t, got["foo"].Max, 3.0)
+	assert.Equal(t, got["bar"].Observations, int64(1))
+
+	// records that can not be merged are an error, but the rest are still
+	// restored.
+	w = data.NewWriterWithOptions(params, data.WriterOptions{
+		Rules: []data.Rule{{
+			Match:  func(metric string) bool { return metric == "bar" },
+			Params: ddsketch.Params{RelativeAccuracy: 0.01},
+		}},
+	})
+	assert.Error(t, c.Replay(ctx, w, fakeSource{}))
+
+	got = make(map[string]data.Record)
+	w.Capture(ctx, func(ctx context.Context, metric string,
+		rec data.Record) bool {
+
+		got[metric] = rec
+		return true
+	})
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got["foo"].Observations, int64(2))
+
+	// corrupt checkpoints are an error
+	assert.NoError(t, ioutil.WriteFile(c.opts.Path, header[:4], 0644))
+	assert.Error(t, c.Replay(ctx, data.NewWriter(params), fakeSource{}))
+}
+
+func TestCheckpointRelative(t *testing.T) {
+	ctx := context.Background()
+	params := tdigest.Params{Compression: 5}
+
+	dir, err := ioutil.TempDir("", "checkpoint-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	// a bare file name is written to and renamed within the working
+	// directory.
+	c := New(Options{Path: "checkpoint"})
+
+	w := data.NewWriter(params)
+	w.Add(ctx, "foo", 1, nil)
+	assert.NoError(t, c.Checkpoint(ctx, w))
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, names, []string{filepath.Join(dir, "checkpoint")})
+}
+
+func TestCheckpointShutdown(t *testing.T) {
+	params := tdigest.Params{Compression: 5}
+
+	dir, err := ioutil.TempDir("", "checkpoint-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the interval never fires, so only the last checkpoint is written.
+	c := New(Options{
+		Path:     filepath.Join(dir, "checkpoint"),
+		Interval: time.Hour,
+	})
+
+	w := data.NewWriter(params)
+	w.Add(context.Background(), "foo", 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx, w))
+
+	w = data.NewWriter(params)
+	assert.NoError(t, c.Replay(context.Background(), w, fakeSource{}))
+	rec, ok, err := w.Load(context.Background(), "foo", 0)
+	assert.NoError(t, err)
+	assert.That(t, ok)
+	assert.Equal(t, rec.Observations, int64(1))
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+// fakeSource maps metrics to the end of their latest record.
+type fakeSource map[string]int64
+
+var _ database.Source = fakeSource(nil)
+
+func (f fakeSource) Query(ctx context.Context, metric string, end int64,
+	buf []byte, cb database.ResultCallback) error {
+	panic("not implemented")
+}
+
+func (f fakeSource) QueryLatest(ctx context.Context, metric string,
+	buf []byte) (start, end int64, data []byte, err error) {
+
+	end, ok := f[metric]
+	if !ok {
+		return 0, 0, nil, os.ErrNotExist
+	}
+	return end, end, nil, nil
+}
+
+func (f fakeSource) Metrics(ctx context.Context,
+	cb func(name string) (bool, error)) error {
+	panic("not implemented")
+}
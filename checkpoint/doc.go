@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package checkpoint periodically saves the records being aggregated by a
+// Writer to disk so that they can be restored after a crash.
+package checkpoint
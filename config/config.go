@@ -16,6 +16,8 @@ type Config struct {
 	Listeners []Entity
 	Database  Entity
 	Dist      Entity
+	DistRules []DistRule
+	Limits    LimitsConfig
 	API       APIConfig
 
 	// keeps track of where the config came from
@@ -31,8 +33,11 @@ func (c *Config) WriteTo(w io.Writer) error {
 
 // MainConfig holds configuration for the main config section.
 type MainConfig struct {
-	Duration time.Duration
-	Plugins  []string
+	Duration           time.Duration
+	Windows            []time.Duration
+	Checkpoint         string
+	CheckpointInterval time.Duration
+	Plugins            []string
 }
 
 // Entity keeps the kind name as well as the abstract form of the config
@@ -42,12 +47,31 @@ type Entity struct {
 	Config interface{}
 }
 
+// DistRule holds the distribution entity for the metrics matching the glob
+// pattern, as specified in the dist.rules config section.
+type DistRule struct {
+	Pattern string
+	Entity
+}
+
+// LimitsConfig holds configuration for the limits config section.
+type LimitsConfig struct {
+	Global      int
+	PerPrefix   int
+	PrefixDepth int
+	Policy      string
+	Overflow    string
+}
+
 // APIConfig holds configuration for the api config section.
 type APIConfig struct {
-	Address  string
-	Origin   string
-	TLS      APITLSConfig
-	Security APISecurityConfig
+	Address    string
+	Origin     string
+	Late       string
+	Tolerance  time.Duration
+	LateSuffix string
+	TLS        APITLSConfig
+	Security   APISecurityConfig
 }
 
 // Redact clears out any potentially sensitive data.
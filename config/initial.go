@@ -11,6 +11,20 @@ var InitialConfig = strings.TrimSpace(`
 #	duration: how often the aggregated distributions are flushed to the
 #	          database.
 #
+#	windows: durations of rolling windows of every metric that are kept in
+#	         memory, sliding forward in steps of a tenth of their duration.
+#	         they are served as live distributions from GET /api/live, and
+#	         are never written to the database. requires a distribution that
+#	         can be merged.
+#
+#	checkpoint: if set, the path of a file that the distributions being
+#	            aggregated are periodically saved to. on startup, they are
+#	            restored from it before any listeners start, so that a crash
+#	            does not lose them. requires a distribution that can be
+#	            merged.
+#
+#	checkpoint_interval: how often the checkpoint is saved. defaults to 1m.
+#
 #	plugins: these files will be loaded at process start and can be used to
 #	         add new kinds of databases or listeners. See the top rothko
 #	         package documentation for how to create a plugin to add more kinds
@@ -19,25 +33,189 @@ var InitialConfig = strings.TrimSpace(`
 
 [main]
 	duration = "10m"
+	# windows = ["1m", "10m", "1h"]
+	# checkpoint = "/var/lib/rothko/checkpoint"
+	# checkpoint_interval = "1m"
 	plugins = [
 		# "my_plugin.so",
 	]
 
 #
 # Multiple listeners can be specified to receive data. There may be multiple
-# kinds of listeners supported, but currently only the graphite wire protocol
-# is built in.
+# kinds of listeners supported, and the graphite (plaintext and pickle), statsd,
+# prometheus (remote_write and scrape), otlp, influx and sketch protocols are
+# built in.
 #
 
 [[listeners.graphite]]
 	address = ":1111"
 
+#
+# The graphite listener can use the timestamp of each value to decide if it is
+# late, meaning it happened before the start of the current duration, or
+# further in the future than the tolerance. Values are never added to the
+# duration their timestamp belongs in: they either go in the current one, or
+# are handled by the late policy.
+#
+#	late: what to do with late values. "accept" (the default) ignores
+#	      timestamps entirely, adding every value to the current duration.
+#	      "separate" adds late values to a metric with the late_suffix
+#	      appended to the name, and "drop" discards them, counting how many
+#	      were dropped.
+#
+#	tolerance: how far before the start of the current duration, or after
+#	           the current time, a value can be without being considered
+#	           late. defaults to "0s".
+#
+#	late_suffix: the suffix for the "separate" policy. defaults to ".late".
+#
+#	protocol: either "tcp" or "udp". defaults to "tcp".
+#
+#	bufsize: the size of the buffer used to read udp datagrams. defaults to
+#	         65535.
+#
+#	max_lines: the maximum number of lines handled from one udp datagram. if
+#	           0 or unset, there is no limit.
+#
+#	id_tag: the key of a graphite tag that is removed from the metric name
+#	        and used as the id of the observation, so that the min and max
+#	        can be traced back to where they came from. lines may also have
+#	        an id as an optional fourth field, which takes precedence.
+#
+
 #
 # example to add a second graphite listener:
 #
 
 # [[listeners.graphite]]
 # 	address = ":2222"
+# 	late = "separate"
+# 	tolerance = "1m"
+
+#
+# example to add a graphite listener over udp:
+#
+
+# [[listeners.graphite]]
+# 	address = ":2003"
+# 	protocol = "udp"
+# 	max_lines = 100
+
+#
+# The graphite_pickle listener receives the pickle protocol as sent by
+# carbon-relay. It has the same options as the graphite listener, except it
+# only supports tcp.
+#
+
+# [[listeners.graphite_pickle]]
+# 	address = ":2004"
+
+#
+# The statsd listener receives udp packets. Timers, histograms and gauges are
+# observed directly, with sampled values weighted by their sample rate, up to
+# a weight of a million. Counters are summed and observed once every
+# interval.
+#
+#	interval: how often summed counters are observed. defaults to "10s".
+#
+#	gauge_ttl: how long a gauge is remembered for relative +N and -N
+#	           adjustments after it was last set. defaults to "10m".
+#
+#	bufsize: the size of the buffer used to read packets. defaults to 65535.
+#
+
+# [[listeners.statsd]]
+# 	address = ":8125"
+# 	interval = "10s"
+
+#
+# The remote_write listener receives snappy compressed prometheus remote write
+# requests over http. Metrics are named after __name__ with the rest of the
+# labels sorted and added as graphite tags, like "name;a=b;c=d". Classic and
+# native histograms are expanded into observations of their bucket midpoints,
+# using only the counts added since the previous write for the series.
+#
+#	path: the http path that accepts writes. defaults to "/api/v1/write".
+#
+
+# [[listeners.remote_write]]
+# 	address = ":9201"
+
+#
+# The scrape listener periodically fetches prometheus text endpoints. Gauges
+# and untyped samples are observed directly, counters (and the _sum and _count
+# of histograms and summaries) are observed as their rate per second, and
+# histogram buckets are expanded like the remote_write listener.
+#
+#	targets: the urls to scrape.
+#
+#	interval: how often to scrape the targets. defaults to "1m".
+#
+#	timeout: how long a scrape may take. defaults to "10s".
+#
+#	name: a Go text/template producing the metric name. It has .Name, .Tags
+#	      (the sorted labels like ";a=b;c=d"), .Labels, .Target and .Instance
+#	      (the host:port of the target). defaults to "{{ .Name }}{{ .Tags }}".
+#
+
+# [[listeners.scrape]]
+# 	targets = ["http://localhost:9100/metrics"]
+# 	interval = "30s"
+# 	name = "node.{{ .Name }}{{ .Tags }}"
+
+#
+# The otlp listener receives OpenTelemetry metrics over http, encoded as
+# protobuf or json. Metrics are named with the data point attributes and some
+# of the resource attributes as graphite tags. Gauges and non-monotonic sums
+# are observed directly, monotonic sums are observed as their rate per second,
+# histograms are expanded into observations of their buckets, and summaries
+# observe each quantile with a quantile tag. Exemplar trace ids are recorded
+# as the ids of the observations.
+#
+#	path: the http path that accepts metrics. defaults to "/v1/metrics".
+#
+#	resource_attributes: the resource attributes included in the name.
+#	                     defaults to ["service.name"].
+#
+
+# [[listeners.otlp]]
+# 	address = ":4318"
+
+#
+# The influx listener receives the InfluxDB line protocol. Every numeric field
+# is observed in a metric named after the measurement and field key, with the
+# tags sorted and added as graphite tags, like "cpu.user;host=a". Over http,
+# it serves the /write and /api/v2/write endpoints. It has the same late,
+# tolerance and late_suffix options as the graphite listener.
+#
+#	protocol: one of "http", "tcp" or "udp". defaults to "http".
+#
+#	precision: the precision of timestamps, one of "ns", "us", "ms", "s", "m"
+#	           or "h". over http, the precision query parameter overrides it.
+#	           defaults to "ns".
+#
+#	bufsize: the size of the buffer used to read udp datagrams. defaults to
+#	         65535.
+#
+
+# [[listeners.influx]]
+# 	address = ":8086"
+# 	late = "drop"
+
+#
+# The sketch listener receives distributions that were already aggregated by
+# the client, like a t-digest computed on an edge device, and merges them into
+# the current record for the metric. The distributions must be the same kind
+# as the configured one. Bodies are POSTed to the path as either a marshaled
+# record with the "metric" query parameter, a marshaled distribution with the
+# "metric" and "kind" query parameters, or a batch of length prefixed metric
+# and record pairs with no query parameters.
+#
+#	path: the http path that accepts sketches. defaults to "/sketch".
+#
+
+# [[listeners.sketch]]
+# 	address = ":9111"
 
 #
 # The files database keeps track of the metric data as a set of files. Each
@@ -88,19 +266,156 @@ var InitialConfig = strings.TrimSpace(`
 # 	handles = 0
 
 #
-# The distribution sketch that the metrics will be stored with. A T-Digest
-# implementation is provided, but more can be added with plugins.
+# The distribution sketch that the metrics will be stored with. T-Digest,
+# DDSketch, HdrHistogram, log-linear histogram and exact implementations are
+# provided, but more can be added with plugins.
 #
 
 [dist.tdigest]
 	compression = 5.0
 
+#
+# DDSketch answers every quantile within a relative error of the true value,
+# which keeps the tails of latency metrics accurate. To use it, replace the
+# dist.tdigest section with a dist.ddsketch section.
+#
+#	relative_accuracy: the relative error of quantiles, at least 0.000001 and
+#	                   less than 1. 0.01 means within 1% of the true value.
+#
+#	bin_limit: the most bins kept for each of the positive and negative
+#	           values. when more would be needed, the values closest to zero
+#	           lose accuracy first. defaults to 2048.
+#
+
+# [dist.ddsketch]
+# 	relative_accuracy = 0.01
+# 	bin_limit = 2048
+
+#
+# HdrHistogram keeps every value to a fixed number of significant figures. To
+# use it, replace the dist.tdigest section with a dist.hdr section.
+#
+#	significant_figures: how many significant figures of every value are
+#	                     kept, from 1 to 5.
+#
+#	lowest_discernible_value: the smallest difference between values that
+#	                          is kept. values are rounded down to a multiple
+#	                          of it, and negative values are counted as
+#	                          zero. defaults to 1.
+#
+#	highest_trackable_value: larger values are counted as this value. if 0
+#	                         or unset, there is no practical limit.
+#
+# The exact minimum and maximum of every record are kept regardless.
+#
+
+# [dist.hdr]
+# 	significant_figures = 3
+# 	lowest_discernible_value = 1
+# 	highest_trackable_value = 3600000
+
+#
+# The log-linear histogram counts every value in a fixed bucket keeping two
+# significant digits, so it merges without loss and is the same on every host.
+# It has no options. To use it, replace the dist.tdigest section with a
+# dist.llhist section. Its size grows with how many powers of ten the values
+# span: values spanning d powers of ten take at most about 20 + 360*d bytes
+# when no bucket has 16384 or more values, which is worth considering when
+# picking the size of a files database record.
+#
+
+# [dist.llhist]
+
+#
+# The exact distribution keeps every value until there are more than a cap,
+# so that metrics with few values have exact quantiles. Past the cap, the
+# values are moved in to the single distribution nested inside it, or a
+# tdigest with the default compression if there is none. It is especially
+# useful in a dist.rules section for low volume metrics.
+#
+#	cap: the most values kept exactly. defaults to 50.
+#
+
+# [dist.exact]
+# 	cap = 50
+# 	[dist.exact.tdigest]
+# 		compression = 5.0
+
+#
+# Metrics can use different distribution parameters than the above with the
+# dist.rules sections. Each rule has a pattern and exactly one distribution.
+# A pattern must match the whole metric name, ignoring case, where "?"
+# matches any single character other than a dot, "*" matches any run of
+# characters other than a dot, and "**" matches any run of characters. For
+# example, "app.*" matches "app.latency" but not "app.latency.p99" or
+# "app.latencies.total", and "app.**" matches all three. The first rule in
+# the file that matches a metric is used. Values merged with sketches must be
+# the same kind as the distribution used for the metric.
+#
+
+# [[dist.rules]]
+# 	pattern = "app.latency"
+# 	[dist.rules.tdigest]
+# 		compression = 20.0
+
+#
+# The limits section bounds how many distinct metrics are accepted, to protect
+# against a bad deploy emitting something like a uuid in every metric name.
+# The metrics are counted for each duration: once a metric is accepted, it is
+# accepted until the records are next written to the database, and then the
+# counts start over, so metrics that stop receiving values make room for new
+# ones.
+#
+#	global: the most metrics accepted. 0 or unset means no limit.
+#
+#	per_prefix: the most metrics accepted with the same prefix. 0 or unset
+#	            means no limit.
+#
+#	prefix_depth: how many dot separated parts of the metric name make up its
+#	              prefix. defaults to 1.
+#
+#	policy: what happens to values for new metrics beyond a limit. "drop"
+#	        (the default) discards them, and "overflow" adds them to the
+#	        overflow metric. either way, they are counted.
+#
+#	overflow: the name of the overflow metric. defaults to "overflow".
+#
+# GET /api/limits lists the prefixes with the most rejected values in the
+# current duration.
+#
+
+# [limits]
+# 	global = 100000
+# 	per_prefix = 10000
+# 	prefix_depth = 2
+# 	policy = "drop"
+
 #
 # The server runs an API for querying the metrics, as well as a web interface
 # for rendering and interacting. The address is the port that the server will
 # listen on, and the origin is used to handle CORS. You may want to limit it
 # in a production deploy.
 #
+# Values can also be pushed to the server with POST /api/ingest. The body is
+# either json or newline delimited json, where every value is an object, or an
+# array of objects, like {"metric": "name", "value": 1.5, "id": "device",
+# "timestamp": 1500000000}. The id and timestamp (in seconds) are optional.
+# Graphite tags in the metric are sorted like the graphite listener does.
+# Timestamps are handled with the same late, tolerance and late_suffix options
+# as the graphite listener, which by default ignore them. The response counts
+# the values added, the ones the late policy found late, and the ones rejected
+# by the limits.
+#
+# If main.windows is set, GET /api/live?metric=name&window=1m returns the
+# record for the metric in that rolling window as json.
+#
+# Records keep a handful of exemplars, the values and ids of observations that
+# had ids, spread out over the range of values instead of sampled at random,
+# so that the rare values in the tails are kept. GET /api/exemplars returns the
+# exemplars of a metric closest to a quantile, with the metric, quantile
+# (default 0.99), now, duration (default 24h) and results (default 10) query
+# parameters.
+#
 
 [api]
 	address = ":8080"
@@ -3,6 +3,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/BurntSushi/toml"
 )
 
@@ -13,16 +15,29 @@ func Load(data []byte) (*Config, error) {
 	// entities that can be added by plugins.
 	var tomlConfig struct {
 		Main struct {
-			Duration textDuration `toml:"duration"`
-			Plugins  []string     `toml:"plugins"`
+			Duration           textDuration   `toml:"duration"`
+			Windows            []textDuration `toml:"windows"`
+			Checkpoint         string         `toml:"checkpoint"`
+			CheckpointInterval textDuration   `toml:"checkpoint_interval"`
+			Plugins            []string       `toml:"plugins"`
 		} `toml:"main"`
 		Listeners map[string][]interface{} `toml:"listeners"`
 		Database  map[string]interface{}   `toml:"database"`
 		Dist      map[string]interface{}   `toml:"dist"`
-		API       struct {
-			Address string `toml:"address"`
-			Origin  string `toml:"origin"`
-			TLS     struct {
+		Limits    struct {
+			Global      int    `toml:"global"`
+			PerPrefix   int    `toml:"per_prefix"`
+			PrefixDepth int    `toml:"prefix_depth"`
+			Policy      string `toml:"policy"`
+			Overflow    string `toml:"overflow"`
+		} `toml:"limits"`
+		API struct {
+			Address    string       `toml:"address"`
+			Origin     string       `toml:"origin"`
+			Late       string       `toml:"late"`
+			Tolerance  textDuration `toml:"tolerance"`
+			LateSuffix string       `toml:"late_suffix"`
+			TLS        struct {
 				Key  string `toml:"key"`
 				Cert string `toml:"cert"`
 			} `toml:"tls"`
@@ -41,22 +56,54 @@ func Load(data []byte) (*Config, error) {
 		return nil, ParseError.New("exactly one database must be specified")
 	}
 
-	if len(tomlConfig.Dist) != 1 {
+	// the rules are not a kind of dist, so they are not counted.
+	rules, err := loadDistRules(tomlConfig.Dist["rules"])
+	if err != nil {
+		return nil, err
+	}
+	_, has_rules := tomlConfig.Dist["rules"]
+	dists := len(tomlConfig.Dist)
+	if has_rules {
+		dists--
+	}
+
+	if dists != 1 {
 		return nil, ParseError.New("exactly one dist must be specified")
 	}
 
+	var windows []time.Duration
+	for _, window := range tomlConfig.Main.Windows {
+		if window.Duration <= 0 {
+			return nil, ParseError.New("invalid window: %v", window.Duration)
+		}
+		windows = append(windows, window.Duration)
+	}
+
+	if tomlConfig.Main.CheckpointInterval.Duration < 0 {
+		return nil, ParseError.New("invalid checkpoint interval: %v",
+			tomlConfig.Main.CheckpointInterval.Duration)
+	}
+
 	conf := &Config{
 		from: tomlConfig,
 
 		Main: MainConfig{
-			Duration: tomlConfig.Main.Duration.Duration,
-			Plugins:  tomlConfig.Main.Plugins,
+			Duration:           tomlConfig.Main.Duration.Duration,
+			Windows:            windows,
+			Checkpoint:         tomlConfig.Main.Checkpoint,
+			CheckpointInterval: tomlConfig.Main.CheckpointInterval.Duration,
+			Plugins:            tomlConfig.Main.Plugins,
 		},
+		DistRules: rules,
+		Limits:    LimitsConfig(tomlConfig.Limits),
 		API: APIConfig{
-			Address:  tomlConfig.API.Address,
-			Origin:   tomlConfig.API.Origin,
-			TLS:      APITLSConfig(tomlConfig.API.TLS),
-			Security: APISecurityConfig(tomlConfig.API.Security),
+			Address:    tomlConfig.API.Address,
+			Origin:     tomlConfig.API.Origin,
+			Late:       tomlConfig.API.Late,
+			Tolerance:  tomlConfig.API.Tolerance.Duration,
+			LateSuffix: tomlConfig.API.LateSuffix,
+			TLS:        APITLSConfig(tomlConfig.API.TLS),
+			Security:   APISecurityConfig(tomlConfig.API.Security),
 		},
 	}
 
@@ -69,6 +116,9 @@ func Load(data []byte) (*Config, error) {
 	}
 
 	for kind, config := range tomlConfig.Dist {
+		if kind == "rules" {
+			continue
+		}
 		conf.Dist = Entity{
 			Kind:   kind,
 			Config: config,
@@ -87,3 +137,41 @@ func Load(data []byte) (*Config, error) {
 
 	return conf, nil
 }
+
+// loadDistRules parses the dist.rules config section. Every rule has a
+// pattern and exactly one kind of dist, like the dist section itself.
+func loadDistRules(config interface{}) (rules []DistRule, err error) {
+	if config == nil {
+		return nil, nil
+	}
+	tables, ok := config.([]map[string]interface{})
+	if !ok {
+		return nil, ParseError.New("dist.rules must be an array of tables")
+	}
+
+	for _, table := range tables {
+		pattern, ok := table["pattern"].(string)
+		if !ok || pattern == "" {
+			return nil, ParseError.New("dist rule requires a pattern")
+		}
+		if len(table) != 2 {
+			return nil, ParseError.New(
+				"exactly one dist must be specified for rule %q", pattern)
+		}
+
+		for kind, config := range table {
+			if kind == "pattern" {
+				continue
+			}
+			rules = append(rules, DistRule{
+				Pattern: pattern,
+				Entity: Entity{
+					Kind:   kind,
+					Config: config,
+				},
+			})
+		}
+	}
+
+	return rules, nil
+}
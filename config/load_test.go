@@ -51,3 +51,92 @@ func TestLoad(t *testing.T) {
 		},
 	})
 }
+
+func TestLoadWindows(t *testing.T) {
+	conf, err := Load([]byte(`
+[main]
+	windows = ["1m", "1h"]
+[database.files]
+[dist.tdigest]
+`))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, conf.Main.Windows,
+		[]time.Duration{time.Minute, time.Hour})
+
+	_, err = Load([]byte(`
+[main]
+	windows = ["0s"]
+[database.files]
+[dist.tdigest]
+`))
+	assert.Error(t, err)
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	conf, err := Load([]byte(`
+[main]
+	checkpoint = "/tmp/checkpoint"
+	checkpoint_interval = "30s"
+[database.files]
+[dist.tdigest]
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, conf.Main.Checkpoint, "/tmp/checkpoint")
+	assert.Equal(t, conf.Main.CheckpointInterval, 30*time.Second)
+
+	_, err = Load([]byte(`
+[main]
+	checkpoint_interval = "-1s"
+[database.files]
+[dist.tdigest]
+`))
+	assert.Error(t, err)
+}
+
+func TestLoadDistRules(t *testing.T) {
+	type D = map[string]interface{}
+
+	conf, err := Load([]byte(`
+[database.files]
+[dist.tdigest]
+	compression = 5.0
+[[dist.rules]]
+	pattern = "app.latency"
+	[dist.rules.tdigest]
+		compression = 20.0
+[[dist.rules]]
+	pattern = "battery*"
+	[dist.rules.other]
+`))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, conf.Dist, Entity{
+		Kind:   "tdigest",
+		Config: D{"compression": 5.0},
+	})
+	assert.DeepEqual(t, conf.DistRules, []DistRule{
+		{Pattern: "app.latency", Entity: Entity{
+			Kind:   "tdigest",
+			Config: D{"compression": 20.0},
+		}},
+		{Pattern: "battery*", Entity: Entity{
+			Kind:   "other",
+			Config: D{},
+		}},
+	})
+
+	_, err = Load([]byte(`
+[database.files]
+[dist.tdigest]
+[[dist.rules]]
+	pattern = "a"
+`))
+	assert.Error(t, err)
+
+	_, err = Load([]byte(`
+[database.files]
+[[dist.rules]]
+	pattern = "a"
+	[dist.rules.tdigest]
+`))
+	assert.Error(t, err)
+}
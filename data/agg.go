@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
 )
 
 // agg aggregates observed values into a record.
@@ -24,6 +25,7 @@ func newAgg(params dist.Params, now time.Time) *agg {
 		rec: Record{
 			StartTime: now.In(time.UTC).UnixNano(),
 			Merged:    1,
+			ExactSums: true,
 		},
 	}
 }
@@ -32,6 +34,12 @@ func newAgg(params dist.Params, now time.Time) *agg {
 // is larger or smaller than the max and min, respectively. The id is copied
 // if it used.
 func (a *agg) Observe(val float64, id []byte) {
+	a.ObserveWeighted(val, 1, id)
+}
+
+// ObserveWeighted is like Observe, except that the value is counted as weight
+// observations. The weight must be positive.
+func (a *agg) ObserveWeighted(val float64, weight int64, id []byte) {
 	a.mu.Lock()
 
 	// add the value into the digest, initializing it if necessary
@@ -40,15 +48,26 @@ func (a *agg) Observe(val float64, id []byte) {
 		if err == nil {
 			a.dist = dist
 		} else {
+			a.mu.Unlock()
 			return
 		}
 	}
-	a.dist.Observe(val)
+	if weight == 1 {
+		a.dist.Observe(val)
+	} else {
+		a.dist.ObserveWeighted(val, weight)
+	}
 
 	// keep track of min, max and obs to update them after dropping the mutex
 	// and bump observations.
 	min, max, obs := a.rec.Min, a.rec.Max, a.rec.Observations
-	a.rec.Observations++
+	a.rec.Observations += weight
+	a.rec.Sum += val * float64(weight)
+	a.rec.SumSquares += val * val * float64(weight)
+	if id != nil {
+		a.rec.Exemplars = insertExemplar(a.rec.Exemplars, val, id,
+			MaxExemplars)
+	}
 
 	a.mu.Unlock()
 
@@ -77,16 +96,78 @@ func (a *agg) Observe(val float64, id []byte) {
 	}
 }
 
+// Merge adds the record with the already unmarshaled distribution into the
+// aggregated record. The distributions must be mergeable.
+func (a *agg) Merge(rec Record, d dist.Dist) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.dist == nil {
+		dist, err := a.params.New()
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		a.dist = dist
+	}
+
+	m, ok := a.dist.(dist.Merger)
+	if !ok {
+		return errs.New("%s distributions can not be merged", a.dist.Kind())
+	}
+	if err := m.Merge(d); err != nil {
+		return errs.Wrap(err)
+	}
+
+	if rec.Observations == 0 {
+		return nil
+	}
+	obs := a.rec.Observations
+	if obs == 0 || rec.Min < a.rec.Min {
+		a.rec.Min = rec.Min
+		a.rec.MinId = append([]byte(nil), rec.MinId...)
+	}
+	if obs == 0 || rec.Max > a.rec.Max {
+		a.rec.Max = rec.Max
+		a.rec.MaxId = append([]byte(nil), rec.MaxId...)
+	}
+	a.rec.Observations += rec.Observations
+	a.rec.Sum += rec.Sum
+	a.rec.SumSquares += rec.SumSquares
+	a.rec.ExactSums = a.rec.ExactSums && rec.HasSums()
+	if len(rec.Exemplars) > 0 {
+		a.rec.Exemplars = copyExemplars(MergeExemplars(MaxExemplars,
+			a.rec.Exemplars, rec.Exemplars))
+	}
+
+	return nil
+}
+
+// MergeAgg merges the other agg into the aggregated record. The distributions
+// must be mergeable.
+func (a *agg) MergeAgg(o *agg) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.dist == nil {
+		return nil
+	}
+	return a.Merge(o.rec, o.dist)
+}
+
 // Finish returns the aggregated record, using the buf to marshal the data
 // and returning the buf. Mutating the returned buf invalidates the record.
 func (a *agg) Finish(buf []byte, now time.Time) ([]byte, Record) {
 	a.mu.Lock()
 	out := a.rec
+	out.Exemplars = append([]Exemplar(nil), a.rec.Exemplars...)
+	if !out.ExactSums {
+		out.Sum, out.SumSquares = 0, 0
+	}
+	out.Kind = a.dist.Kind()
+	buf = a.dist.Marshal(buf[:0])
 	a.mu.Unlock()
 
 	out.EndTime = now.In(time.UTC).UnixNano()
-	out.Kind = a.dist.Kind()
-	buf = a.dist.Marshal(buf[:0])
 	out.Distribution = buf
 
 	return buf, out
@@ -31,6 +31,53 @@ func TestAgg(t *testing.T) {
 	assert.That(t, len(rec.Distribution) > 0)
 }
 
+func TestAggWeighted(t *testing.T) {
+	var params fakeParams
+	a := newAgg(params, time.Now())
+
+	a.ObserveWeighted(2, 5, []byte("2"))
+	a.Observe(1, []byte("1"))
+	a.ObserveWeighted(3, 1000, []byte("3"))
+
+	_, rec := a.Finish(nil, time.Now())
+
+	assert.Equal(t, rec.Observations, int64(1006))
+	assert.Equal(t, string(rec.MinId), "1")
+	assert.Equal(t, string(rec.MaxId), "3")
+}
+
+func TestAggMerge(t *testing.T) {
+	var params fakeParams
+	a := newAgg(params, time.Now())
+
+	a.Observe(5, []byte("5"))
+	assert.NoError(t, a.Merge(Record{
+		Observations: 10,
+		Min:          1,
+		MinId:        []byte("1"),
+		Max:          3,
+		MaxId:        []byte("3"),
+	}, fakeDist{}))
+	assert.NoError(t, a.Merge(Record{}, fakeDist{}))
+
+	_, rec := a.Finish(nil, time.Now())
+
+	assert.Equal(t, rec.Observations, int64(11))
+	assert.Equal(t, rec.Min, float64(1))
+	assert.Equal(t, string(rec.MinId), "1")
+	assert.Equal(t, rec.Max, float64(5))
+	assert.Equal(t, string(rec.MaxId), "5")
+}
+
+func TestAggNewError(t *testing.T) {
+	a := newAgg(badParams{}, time.Now())
+
+	// the mutex must be released when the dist can not be made.
+	a.Observe(1, nil)
+	a.Observe(2, nil)
+	assert.Error(t, a.Merge(Record{}, fakeDist{}))
+}
+
 func BenchmarkAgg(b *testing.B) {
 	a := newAgg(fakeParams{}, time.Now())
 
@@ -2,15 +2,38 @@
 
 package data
 
-import "github.com/vivint/rothko/dist"
+import (
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
 
 type fakeParams struct{ dist.Params }
 
 func (f fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
 func (f fakeParams) Kind() string            { return "fake" }
+func (f fakeParams) Unmarshal(data []byte) (dist.Dist, error) {
+	return fakeDist{}, nil
+}
 
 type fakeDist struct{ dist.Dist }
 
-func (f fakeDist) Kind() string               { return "fake" }
-func (f fakeDist) Observe(float64)            {}
-func (f fakeDist) Marshal(data []byte) []byte { return append(data, 0) }
+func (f fakeDist) Kind() string                   { return "fake" }
+func (f fakeDist) Observe(float64)                {}
+func (f fakeDist) ObserveWeighted(float64, int64) {}
+func (f fakeDist) Marshal(data []byte) []byte     { return append(data, 0) }
+func (f fakeDist) Merge(dist.Dist) error          { return nil }
+func (f fakeDist) Len() int64                     { return 0 }
+func (f fakeDist) Query(float64) float64          { return 0 }
+
+type otherParams struct{ fakeParams }
+
+func (o otherParams) New() (dist.Dist, error) { return otherDist{}, nil }
+func (o otherParams) Kind() string            { return "other" }
+
+type otherDist struct{ fakeDist }
+
+func (o otherDist) Kind() string { return "other" }
+
+type badParams struct{ fakeParams }
+
+func (b badParams) New() (dist.Dist, error) { return nil, errs.New("bad") }
@@ -0,0 +1,94 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import "sort"
+
+// MaxExemplars is the most exemplars kept in a record.
+const MaxExemplars = 16
+
+//
+// exemplars are not kept with a uniform random reservoir on purpose. such a
+// reservoir keeps values in proportion to how often they happen, so with only
+// MaxExemplars slots, it almost never holds anything near the 99th percentile,
+// which is what the exemplars are for. instead, the exemplars are a bounded
+// set kept spread out over the range of values, always including the
+// smallest and largest. that makes them a sample of the values, rather than
+// of the observations, and it is deterministic, so merging the same records
+// always gives the same exemplars.
+//
+
+// insertExemplar adds an exemplar with the value to the exemplars, which
+// must be sorted by value, and keeps at most max of them. The id is only
+// copied if the exemplar is kept.
+func insertExemplar(exs []Exemplar, value float64, id []byte,
+	max int) []Exemplar {
+
+	index := sort.Search(len(exs), func(i int) bool {
+		return exs[i].Value >= value
+	})
+
+	exs = append(exs, Exemplar{})
+	copy(exs[index+1:], exs[index:])
+	exs[index] = Exemplar{Value: value}
+
+	if len(exs) > max {
+		evict := evictIndex(exs)
+		exs = append(exs[:evict], exs[evict+1:]...)
+		if evict == index {
+			return exs
+		}
+		if evict < index {
+			index--
+		}
+	}
+
+	exs[index].Id = append([]byte(nil), id...)
+	return exs
+}
+
+// MergeExemplars combines the sets of exemplars, keeping at most max of them
+// spread out over the range of values. The returned exemplars are sorted by
+// value and share ids with the inputs.
+func MergeExemplars(max int, sets ...[]Exemplar) []Exemplar {
+	var out []Exemplar
+	for _, exs := range sets {
+		out = append(out, exs...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Value < out[j].Value
+	})
+	for len(out) > max {
+		evict := evictIndex(out)
+		out = append(out[:evict], out[evict+1:]...)
+	}
+	return out
+}
+
+// evictIndex returns the index of the exemplar to drop from the sorted
+// exemplars. The smallest and largest are always kept, and otherwise the one
+// in the most crowded part of the values is dropped, so that the exemplars
+// stay spread out, and the rare values in the tails are kept around.
+func evictIndex(exs []Exemplar) int {
+	if len(exs) < 3 {
+		return len(exs) - 1
+	}
+
+	evict := 1
+	gap := exs[2].Value - exs[0].Value
+	for i := 2; i < len(exs)-1; i++ {
+		if g := exs[i+1].Value - exs[i-1].Value; g < gap {
+			evict, gap = i, g
+		}
+	}
+	return evict
+}
+
+// copyExemplars copies the ids of the exemplars so that they no longer share
+// memory with where they came from.
+func copyExemplars(exs []Exemplar) []Exemplar {
+	for i := range exs {
+		exs[i].Id = append([]byte(nil), exs[i].Id...)
+	}
+	return exs
+}
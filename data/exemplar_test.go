@@ -0,0 +1,45 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestExemplars(t *testing.T) {
+	a := newAgg(fakeParams{}, time.Now())
+	for _, i := range rand.Perm(1000) {
+		a.Observe(float64(i), []byte(fmt.Sprint(i)))
+	}
+	a.Observe(-1, nil)
+
+	_, rec := a.Finish(nil, time.Now())
+	exs := rec.Exemplars
+
+	assert.Equal(t, len(exs), MaxExemplars)
+	assert.That(t, sort.SliceIsSorted(exs, func(i, j int) bool {
+		return exs[i].Value < exs[j].Value
+	}))
+	assert.Equal(t, exs[0].Value, float64(0))
+	assert.Equal(t, exs[len(exs)-1].Value, float64(999))
+	for _, ex := range exs {
+		assert.Equal(t, string(ex.Id), fmt.Sprint(ex.Value))
+	}
+
+	merged := MergeExemplars(4, exs, []Exemplar{{Value: 5000}})
+	assert.Equal(t, len(merged), 4)
+	assert.Equal(t, merged[0].Value, float64(0))
+	assert.Equal(t, merged[3].Value, float64(5000))
+
+	data, err := rec.Marshal()
+	assert.NoError(t, err)
+	var got Record
+	assert.NoError(t, got.Unmarshal(data))
+	assert.DeepEqual(t, got.Exemplars, rec.Exemplars)
+}
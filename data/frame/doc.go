@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package frame provides the length prefixed framing of metrics and records
+// shared by sketch batches and checkpoints.
+package frame
@@ -0,0 +1,59 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package frame
+
+import (
+	"encoding/binary"
+
+	"github.com/vivint/rothko/data"
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors originating at this package.
+var Error = errs.Class("frame")
+
+// Append appends the metric and the marshaled record to the buffer as a
+// frame: the uvarint length of the metric, the metric, the uvarint length of
+// the record and the record.
+func Append(buf []byte, metric string, rec data.Record) ([]byte, error) {
+	size := rec.Size()
+	buf = binary.AppendUvarint(buf, uint64(len(metric)))
+	buf = append(buf, metric...)
+	buf = binary.AppendUvarint(buf, uint64(size))
+
+	start := len(buf)
+	buf = append(buf, make([]byte, size)...)
+	if _, err := rec.MarshalTo(buf[start:]); err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return buf, nil
+}
+
+// Next parses the frame at the start of the buffer, returning the metric,
+// the marshaled record, and the rest of the buffer. The record aliases the
+// buffer.
+func Next(buf []byte) (metric string, record, rest []byte, err error) {
+	metric_data, buf, err := readChunk(buf)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	record, buf, err = readChunk(buf)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return string(metric_data), record, buf, nil
+}
+
+// readChunk reads a uvarint length prefixed chunk out of the buffer,
+// returning it and the rest of the buffer.
+func readChunk(buf []byte) (chunk, rest []byte, err error) {
+	size, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, nil, Error.New("invalid frame length")
+	}
+	buf = buf[n:]
+	if size > uint64(len(buf)) {
+		return nil, nil, Error.New("truncated frame")
+	}
+	return buf[:size], buf[size:], nil
+}
@@ -0,0 +1,41 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package frame
+
+import (
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestFrame(t *testing.T) {
+	buf, err := Append(nil, "foo", data.Record{Observations: 1, Kind: "k"})
+	assert.NoError(t, err)
+	buf, err = Append(buf, "bar", data.Record{Observations: 2, Kind: "k"})
+	assert.NoError(t, err)
+
+	var metrics []string
+	var obs []int64
+	for rest := buf; len(rest) > 0; {
+		var metric string
+		var record []byte
+		metric, record, rest, err = Next(rest)
+		assert.NoError(t, err)
+
+		var rec data.Record
+		assert.NoError(t, rec.Unmarshal(record))
+		metrics = append(metrics, metric)
+		obs = append(obs, rec.Observations)
+	}
+	assert.DeepEqual(t, metrics, []string{"foo", "bar"})
+	assert.DeepEqual(t, obs, []int64{1, 2})
+
+	// every truncation of a single frame is an error.
+	one, err := Append(nil, "foo", data.Record{Observations: 1, Kind: "k"})
+	assert.NoError(t, err)
+	for i := 0; i < len(one); i++ {
+		_, _, _, err := Next(one[:i])
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vivint/rothko/external"
+	"github.com/zeebo/errs"
+)
+
+// maxRejectedPrefixes bounds how many prefixes with no admitted metrics are
+// tracked, so that the rejections can't grow without bound either.
+const maxRejectedPrefixes = 1000
+
+// LimitPolicy controls what happens to new metrics beyond a cardinality
+// limit.
+type LimitPolicy string
+
+const (
+	// LimitDrop discards the values for new metrics beyond the limit.
+	LimitDrop LimitPolicy = "drop"
+
+	// LimitOverflow adds the values for new metrics beyond the limit to the
+	// overflow metric.
+	LimitOverflow LimitPolicy = "overflow"
+)
+
+// ParseLimitPolicy returns the LimitPolicy for the string, where the empty
+// string is LimitDrop.
+func ParseLimitPolicy(policy string) (LimitPolicy, error) {
+	switch LimitPolicy(policy) {
+	case "":
+		return LimitDrop, nil
+	case LimitDrop, LimitOverflow:
+		return LimitPolicy(policy), nil
+	default:
+		return "", errs.New("unknown limit policy: %q", policy)
+	}
+}
+
+// Limits controls how many distinct metrics a Writer will accept between
+// calls to Capture. Once a metric is admitted, it is accepted until the next
+// Capture, when the counts start over, so metrics that stop receiving values
+// make room for new ones.
+type Limits struct {
+	// Global is the most metrics that are admitted. If zero, there is no
+	// global limit.
+	Global int
+
+	// PerPrefix is the most metrics that are admitted with the same prefix.
+	// If zero, there is no per prefix limit.
+	PerPrefix int
+
+	// PrefixDepth is how many dot separated components of the metric name,
+	// ignoring any graphite tags, make up its prefix. Defaults to 1.
+	PrefixDepth int
+
+	// Policy controls what happens to new metrics beyond a limit. Defaults
+	// to LimitDrop.
+	Policy LimitPolicy
+
+	// Overflow is the metric used by the LimitOverflow policy. It is always
+	// admitted. Defaults to "overflow".
+	Overflow string
+}
+
+// PrefixStats is how many metrics with a prefix were admitted, and how many
+// values for new metrics with the prefix were rejected by the limits.
+type PrefixStats struct {
+	Prefix   string
+	Metrics  int64
+	Rejected int64
+}
+
+// limiter admits metrics according to some Limits.
+type limiter struct {
+	opts     Limits
+	admitted atomic.Value // *sync.Map of map[string]struct{}
+
+	mu       sync.Mutex
+	total    int64
+	rejected int64 // number of prefixes with no admitted metrics
+	prefixes map[string]*PrefixStats
+}
+
+// newLimiter returns a limiter for the limits, or nil if there are no limits.
+func newLimiter(opts Limits) *limiter {
+	if opts.Global <= 0 && opts.PerPrefix <= 0 {
+		return nil
+	}
+	if opts.PrefixDepth <= 0 {
+		opts.PrefixDepth = 1
+	}
+	if opts.Policy == "" {
+		opts.Policy = LimitDrop
+	}
+	if opts.Overflow == "" {
+		opts.Overflow = "overflow"
+	}
+
+	l := &limiter{
+		opts:     opts,
+		prefixes: make(map[string]*PrefixStats),
+	}
+	l.admitted.Store(new(sync.Map))
+	return l
+}
+
+// Reset forgets every admitted metric and all of the stats.
+func (l *limiter) Reset() {
+	l.mu.Lock()
+	l.admitted.Store(new(sync.Map))
+	l.total = 0
+	l.rejected = 0
+	l.prefixes = make(map[string]*PrefixStats)
+	l.mu.Unlock()
+}
+
+// Admit returns the metric the values for the metric should be added to, and
+// false if they should be dropped.
+func (l *limiter) Admit(metric string) (string, bool) {
+	if metric == l.opts.Overflow {
+		return metric, true
+	}
+	if _, ok := l.admitted.Load().(*sync.Map).Load(metric); ok {
+		return metric, true
+	}
+
+	prefix := metricPrefix(metric, l.opts.PrefixDepth)
+
+	l.mu.Lock()
+	admitted := l.admitted.Load().(*sync.Map)
+	if _, ok := admitted.Load(metric); ok {
+		l.mu.Unlock()
+		return metric, true
+	}
+
+	stats := l.prefixes[prefix]
+	over := l.opts.Global > 0 && l.total >= int64(l.opts.Global) ||
+		l.opts.PerPrefix > 0 && stats != nil &&
+			stats.Metrics >= int64(l.opts.PerPrefix)
+
+	if !over {
+		if stats == nil {
+			stats = &PrefixStats{Prefix: prefix}
+			l.prefixes[prefix] = stats
+		} else if stats.Metrics == 0 {
+			l.rejected--
+		}
+		stats.Metrics++
+		l.total++
+		admitted.Store(metric, struct{}{})
+		l.mu.Unlock()
+		return metric, true
+	}
+
+	if stats == nil && l.rejected < maxRejectedPrefixes {
+		stats = &PrefixStats{Prefix: prefix}
+		l.prefixes[prefix] = stats
+		l.rejected++
+	}
+	if stats != nil {
+		stats.Rejected++
+	}
+	l.mu.Unlock()
+
+	if l.opts.Policy == LimitOverflow {
+		external.Observe("metric_limit_overflowed", 1)
+		return l.opts.Overflow, true
+	}
+	external.Observe("metric_limit_dropped", 1)
+	return "", false
+}
+
+// Stats returns the number of admitted metrics, and the stats of the n
+// prefixes with the most rejections, breaking ties by the most metrics.
+func (l *limiter) Stats(n int) (int64, []PrefixStats) {
+	if n < 0 {
+		n = 0
+	}
+
+	l.mu.Lock()
+	total := l.total
+	out := make([]PrefixStats, 0, len(l.prefixes))
+	for _, stats := range l.prefixes {
+		out = append(out, *stats)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rejected != out[j].Rejected {
+			return out[i].Rejected > out[j].Rejected
+		}
+		if out[i].Metrics != out[j].Metrics {
+			return out[i].Metrics > out[j].Metrics
+		}
+		return out[i].Prefix < out[j].Prefix
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return total, out
+}
+
+// metricPrefix returns the first depth dot separated components of the
+// metric name, ignoring any graphite tags.
+func metricPrefix(metric string, depth int) string {
+	if index := strings.IndexByte(metric, ';'); index >= 0 {
+		metric = metric[:index]
+	}
+	for i := 0; i < len(metric); i++ {
+		if metric[i] != '.' {
+			continue
+		}
+		depth--
+		if depth == 0 {
+			return metric[:i]
+		}
+	}
+	return metric
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestMetricPrefix(t *testing.T) {
+	assert.Equal(t, metricPrefix("a.b.c", 1), "a")
+	assert.Equal(t, metricPrefix("a.b.c", 2), "a.b")
+	assert.Equal(t, metricPrefix("a.b.c", 5), "a.b.c")
+	assert.Equal(t, metricPrefix("a.b;c=d.e", 2), "a.b")
+}
+
+func TestWriterLimitsAddAt(t *testing.T) {
+	ctx := context.Background()
+
+	w := NewWriterWithOptions(fakeParams{}, WriterOptions{
+		Limits: Limits{Global: 1},
+	})
+	assert.Equal(t, w.AddAt(ctx, "a", time.Time{}, 1, nil), NotDropped)
+	assert.Equal(t, w.AddAt(ctx, "b", time.Time{}, 1, nil), DropLimited)
+}
+
+func TestWriterLimits(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(limits Limits) map[string]int64 {
+		w := NewWriterWithOptions(fakeParams{}, WriterOptions{Limits: limits})
+		for i := 0; i < 10; i++ {
+			w.Add(ctx, fmt.Sprintf("app.%d", i), 1, nil)
+			w.Add(ctx, fmt.Sprintf("sys.%d", i), 1, nil)
+		}
+		w.Add(ctx, "app.0", 1, nil)
+
+		got := make(map[string]int64)
+		w.Capture(ctx, func(ctx context.Context, metric string,
+			rec Record) bool {
+
+			got[metric] = rec.Observations
+			return true
+		})
+		return got
+	}
+
+	assert.DeepEqual(t, run(Limits{Global: 3}), map[string]int64{
+		"app.0": 2, "sys.0": 1, "app.1": 1,
+	})
+	assert.DeepEqual(t, run(Limits{PerPrefix: 1}), map[string]int64{
+		"app.0": 2, "sys.0": 1,
+	})
+	assert.DeepEqual(t, run(Limits{
+		Global:   2,
+		Policy:   LimitOverflow,
+		Overflow: "over",
+	}), map[string]int64{
+		"app.0": 2, "sys.0": 1, "over": 18,
+	})
+
+	w := NewWriterWithOptions(fakeParams{}, WriterOptions{
+		Limits: Limits{PerPrefix: 2, PrefixDepth: 2},
+	})
+	for i := 0; i < 5; i++ {
+		w.Add(ctx, fmt.Sprintf("a.uuid%d.x", i), 1, nil)
+		w.Add(ctx, fmt.Sprintf("a.b.%d", i), 1, nil)
+		w.Add(ctx, fmt.Sprintf("a.b.%d", i), 1, nil)
+	}
+
+	metrics, prefixes, ok := w.LimitStats(2)
+	assert.That(t, ok)
+	assert.Equal(t, metrics, int64(7))
+	assert.DeepEqual(t, prefixes, []PrefixStats{
+		{Prefix: "a.b", Metrics: 2, Rejected: 6},
+		{Prefix: "a.uuid0", Metrics: 1, Rejected: 0},
+	})
+
+	_, prefixes, _ = w.LimitStats(-1)
+	assert.Equal(t, len(prefixes), 0)
+
+	// capturing starts the counts over, so new metrics are admitted.
+	w.Capture(ctx, func(ctx context.Context, metric string,
+		rec Record) bool {
+
+		return true
+	})
+	metrics, _, _ = w.LimitStats(2)
+	assert.Equal(t, metrics, int64(0))
+	w.Add(ctx, "a.b.new", 1, nil)
+	got := 0
+	w.Capture(ctx, func(ctx context.Context, metric string,
+		rec Record) bool {
+
+		got++
+		assert.Equal(t, metric, "a.b.new")
+		return true
+	})
+	assert.Equal(t, got, 1)
+
+	_, _, ok = NewWriter(fakeParams{}).LimitStats(2)
+	assert.That(t, !ok)
+}
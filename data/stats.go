@@ -0,0 +1,38 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import "math"
+
+// HasSums returns true if the sum and sum of squares of the record include
+// every observed value, and so the Mean and Stddev are exact.
+func (m Record) HasSums() bool {
+	return m.ExactSums
+}
+
+// Mean returns the mean of the values in the record computed from the sum.
+// It is only exact if HasSums returns true, and is zero if there are no
+// observations.
+func (m Record) Mean() float64 {
+	if m.Observations == 0 {
+		return 0
+	}
+	return m.Sum / float64(m.Observations)
+}
+
+// Stddev returns the population standard deviation of the values in the
+// record computed from the sum and sum of squares. It is only exact if
+// HasSums returns true, and is zero if there are no observations. Rounding
+// can make the computed variance slightly negative when the values are all
+// about the same, so it is clamped to zero.
+func (m Record) Stddev() float64 {
+	if m.Observations == 0 {
+		return 0
+	}
+	mean := m.Mean()
+	variance := m.SumSquares/float64(m.Observations) - mean*mean
+	if variance <= 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestRecordSums(t *testing.T) {
+	a := newAgg(fakeParams{}, time.Now())
+	a.Observe(2, nil)
+	a.Observe(4, nil)
+	a.ObserveWeighted(6, 2, nil)
+
+	_, rec := a.Finish(nil, time.Now())
+	assert.Equal(t, rec.Sum, float64(18))
+	assert.Equal(t, rec.SumSquares, float64(92))
+	assert.That(t, rec.HasSums())
+	assert.Equal(t, rec.Mean(), 4.5)
+	assert.That(t, math.Abs(rec.Stddev()-math.Sqrt(2.75)) < 1e-9)
+
+	data, err := rec.Marshal()
+	assert.NoError(t, err)
+	var got Record
+	assert.NoError(t, got.Unmarshal(data))
+	assert.Equal(t, got.Sum, rec.Sum)
+	assert.Equal(t, got.SumSquares, rec.SumSquares)
+
+	assert.That(t, got.HasSums())
+
+	// merging in a record without sums, like one from before the sums
+	// existed, leaves the result without them.
+	a.Merge(Record{Observations: 1, Sum: 1, SumSquares: 1}, fakeDist{})
+	_, rec = a.Finish(nil, time.Now())
+	assert.That(t, !rec.HasSums())
+	assert.Equal(t, rec.Sum, float64(0))
+	assert.Equal(t, (Record{}).Mean(), float64(0))
+
+	// rounding can make the variance negative, and the stddev is zero then.
+	assert.Equal(t, (Record{
+		Observations: 3,
+		Sum:          0.30000000000000004,
+		SumSquares:   0.030000000000000006,
+		ExactSums:    true,
+	}).Stddev(), float64(0))
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
+
+// windowSlots is how many pages a rolling window is split into. The window
+// slides forward in steps of its duration divided by windowSlots.
+const windowSlots = 10
+
+// window keeps a ring of pages that together cover a rolling duration.
+type window struct {
+	dur   time.Duration
+	step  time.Duration
+	slots [windowSlots]unsafe.Pointer // contains *page
+}
+
+// newWindow returns a window covering the duration.
+func newWindow(dur time.Duration) *window {
+	step := dur / windowSlots
+	if step <= 0 {
+		step = 1
+	}
+	return &window{
+		dur:  dur,
+		step: step,
+	}
+}
+
+// slot returns the page for the step containing now, replacing any page in
+// the ring from an earlier step.
+func (w *window) slot(now time.Time) *page {
+	start := now.Truncate(w.step)
+	ptr := &w.slots[(start.UnixNano()/int64(w.step))%windowSlots]
+
+	for {
+		pi := atomic.LoadPointer(ptr)
+		if pi != nil && !(*page)(pi).now.Before(start) {
+			return (*page)(pi)
+		}
+
+		// the page is from an earlier step, so replace it. if we lose the
+		// race, the winner's page is loaded on the next pass.
+		atomic.CompareAndSwapPointer(ptr, pi, unsafe.Pointer(newPage(start)))
+	}
+}
+
+// pages returns the pages in the ring that started within the duration
+// before now, and the earliest start time of them.
+func (w *window) pages(now time.Time) (pages []*page, start time.Time) {
+	cutoff := now.Add(-w.dur)
+	start = now
+
+	for i := range w.slots {
+		pi := atomic.LoadPointer(&w.slots[i])
+		if pi == nil {
+			continue
+		}
+		p := (*page)(pi)
+		if !p.now.After(cutoff) || p.now.After(now) {
+			continue
+		}
+		if p.now.Before(start) {
+			start = p.now
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, start
+}
+
+// mergePages merges the aggs for the metric in all of the pages into a new
+// agg starting at the start time. It returns nil if no page has the metric.
+func mergePages(params dist.Params, metric string, pages []*page,
+	start time.Time) (*agg, error) {
+
+	var out *agg
+	for _, p := range pages {
+		ai, ok := p.m.Load(metric)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = newAgg(params, start)
+		}
+		if err := out.MergeAgg(ai.(*agg)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// findWindow returns the rolling window with the duration.
+func (s *Writer) findWindow(dur time.Duration) (*window, error) {
+	for _, win := range s.windows {
+		if win.dur == dur {
+			return win, nil
+		}
+	}
+	return nil, errs.New("no window for duration: %v", dur)
+}
+
+// eachWindow calls fn with the current page of every rolling window.
+func (s *Writer) eachWindow(fn func(p *page)) {
+	if len(s.windows) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, win := range s.windows {
+		fn(win.slot(now))
+	}
+}
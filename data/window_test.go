@@ -0,0 +1,107 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestWindow(t *testing.T) {
+	var params fakeParams
+	win := newWindow(time.Minute)
+	now := time.Unix(1000, 0).Truncate(win.step)
+
+	win.slot(now).observe(params, "a", 1, 1, nil)
+	win.slot(now.Add(time.Second)).observe(params, "a", 2, 1, nil)
+	win.slot(now.Add(30*time.Second)).observe(params, "b", 3, 1, nil)
+
+	pages, start := win.pages(now.Add(30 * time.Second))
+	assert.Equal(t, len(pages), 2)
+	assert.That(t, start.Equal(now))
+
+	a, err := mergePages(params, "a", pages, start)
+	assert.NoError(t, err)
+	_, rec := a.Finish(nil, now)
+	assert.Equal(t, rec.Observations, int64(2))
+	assert.Equal(t, rec.Min, float64(1))
+	assert.Equal(t, rec.Max, float64(2))
+
+	// a minute later, the first step has slid out of the window and its slot
+	// is reused.
+	later := now.Add(time.Minute)
+	win.slot(later).observe(params, "a", 4, 1, nil)
+
+	pages, start = win.pages(later)
+	assert.Equal(t, len(pages), 2)
+	assert.That(t, start.Equal(now.Add(30*time.Second)))
+
+	a, err = mergePages(params, "a", pages, start)
+	assert.NoError(t, err)
+	_, rec = a.Finish(nil, later)
+	assert.Equal(t, rec.Observations, int64(1))
+	assert.Equal(t, rec.Min, float64(4))
+}
+
+func TestWriterWindows(t *testing.T) {
+	ctx := context.Background()
+
+	w := NewWindowedWriter(fakeParams{}, time.Minute, time.Hour)
+	assert.DeepEqual(t, w.Windows(), []time.Duration{time.Minute, time.Hour})
+
+	w.Add(ctx, "1", 1, nil)
+	w.AddWeighted(ctx, "1", 2, 3, nil)
+	w.Add(ctx, "2", 2, nil)
+
+	// capturing the flushed records leaves the windows alone.
+	w.Capture(ctx, func(ctx context.Context, metric string, rec Record) bool {
+		return true
+	})
+
+	got := make(map[string]int64)
+	assert.NoError(t, w.Iterate(ctx, time.Hour,
+		func(ctx context.Context, metric string, rec Record) bool {
+			got[metric] = rec.Observations
+			return true
+		}))
+	assert.DeepEqual(t, got, map[string]int64{"1": 4, "2": 1})
+
+	rec, ok, err := w.Load(ctx, "1", time.Minute)
+	assert.NoError(t, err)
+	assert.That(t, ok)
+	assert.Equal(t, rec.Observations, int64(4))
+	assert.Equal(t, rec.Max, float64(2))
+
+	_, ok, err = w.Load(ctx, "3", time.Minute)
+	assert.NoError(t, err)
+	assert.That(t, !ok)
+
+	_, _, err = w.Load(ctx, "1", time.Second)
+	assert.Error(t, err)
+	assert.Error(t, w.Iterate(ctx, time.Second,
+		func(ctx context.Context, metric string, rec Record) bool {
+			return true
+		}))
+
+	// the flush window was emptied by the capture.
+	w.Add(ctx, "3", 1, nil)
+	got = make(map[string]int64)
+	assert.NoError(t, w.Iterate(ctx, 0,
+		func(ctx context.Context, metric string, rec Record) bool {
+			got[metric] = rec.Observations
+			return true
+		}))
+	assert.DeepEqual(t, got, map[string]int64{"3": 1})
+
+	rec, ok, err = w.Load(ctx, "3", 0)
+	assert.NoError(t, err)
+	assert.That(t, ok)
+	assert.Equal(t, rec.Observations, int64(1))
+
+	_, ok, err = w.Load(ctx, "1", 0)
+	assert.NoError(t, err)
+	assert.That(t, !ok)
+}
@@ -5,19 +5,23 @@ package data
 import (
 	"context"
 	"math"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
 )
 
 // page keeps track of a mapping of metric name strings to *agg with a time
-// that all of the aggs will start at.
+// that all of the aggs will start at. It also counts the number of writes in
+// flight so that it can be drained before it is captured.
 type page struct {
-	m   sync.Map // map[string]*agg
-	now time.Time
+	refs int64    // atomic: writes in flight
+	m    sync.Map // map[string]*agg
+	now  time.Time
 }
 
 // newPage creates a new page for the scribbler.
@@ -27,10 +31,84 @@ func newPage(now time.Time) *page {
 	}
 }
 
+// release signals that a write acquired with acquirePage is done.
+func (p *page) release() {
+	atomic.AddInt64(&p.refs, -1)
+}
+
+// drain waits for all of the writes in flight to the page to finish. It must
+// only be called once the page is no longer reachable by new writes.
+func (p *page) drain() {
+	for atomic.LoadInt64(&p.refs) > 0 {
+		runtime.Gosched()
+	}
+}
+
+// observe adds the value to the agg for the metric in the page, creating one
+// with the params if necessary.
+func (p *page) observe(params dist.Params, metric string, value float64,
+	weight int64, id []byte) {
+
+	ai, ok := p.m.Load(metric)
+	if !ok {
+		// we use LoadOrStore here to avoid a mutex at the cost of wasted
+		// allocations for losers during contention.
+		ai, _ = p.m.LoadOrStore(metric, newAgg(params, p.now))
+	}
+	a := ai.(*agg)
+
+	a.ObserveWeighted(value, weight, id)
+}
+
+// merge merges the record into the agg for the metric in the page, creating
+// one with the params if necessary.
+func (p *page) merge(params dist.Params, metric string, rec Record,
+	d dist.Dist) error {
+
+	ai, ok := p.m.Load(metric)
+	if !ok {
+		ai, _ = p.m.LoadOrStore(metric, newAgg(params, p.now))
+	}
+	return ai.(*agg).Merge(rec, d)
+}
+
+// Rule selects the params used for the metrics it matches.
+type Rule struct {
+	// Match returns true if the rule applies to the metric.
+	Match func(metric string) bool
+
+	// Params are used to create the distributions of matching metrics.
+	Params dist.Params
+}
+
+// WriterOptions controls the optional behavior of a Writer.
+type WriterOptions struct {
+	// Windows are the durations of rolling windows kept for every metric in
+	// addition to the records returned by Capture. See NewWindowedWriter.
+	Windows []time.Duration
+
+	// Rules select the params for a metric. The first rule that matches is
+	// used, and metrics that match no rule use the Writer's params.
+	Rules []Rule
+
+	// Limits bound how many distinct metrics the Writer accepts.
+	Limits Limits
+}
+
 // Writer keeps track of the distributions of a collection of metrics.
 type Writer struct {
-	page   unsafe.Pointer // contains *page
-	params dist.Params
+	page    unsafe.Pointer // contains *page
+	params  dist.Params
+	windows []*window
+	rules   []Rule
+	limiter *limiter // nil if there are no limits
+
+	// selected caches the params selected by the rules for the metrics added
+	// since the last Capture.
+	selected atomic.Value // *sync.Map of string to dist.Params
+
+	// mu serializes calls to Capture.
+	mu sync.Mutex
 }
 
 // NewWriter makes a Writer that will return distributions using the
@@ -41,9 +119,88 @@ func NewWriter(params dist.Params) *Writer {
 	}
 }
 
+// NewWindowedWriter makes a Writer that, in addition to the records returned
+// by Capture, keeps a rolling window of every metric for each of the
+// durations. The windows are read with Iterate and Load, and slide forward in
+// steps of a tenth of their duration. The distributions
+// created by the params must support merging.
+func NewWindowedWriter(params dist.Params,
+	windows ...time.Duration) *Writer {
+
+	return NewWriterWithOptions(params, WriterOptions{
+		Windows: windows,
+	})
+}
+
+// NewWriterWithOptions makes a Writer that uses the params for any metric
+// that does not match one of the rules in the options.
+func NewWriterWithOptions(params dist.Params, opts WriterOptions) *Writer {
+	w := NewWriter(params)
+	w.rules = opts.Rules
+	w.selected.Store(new(sync.Map))
+	w.limiter = newLimiter(opts.Limits)
+	for _, dur := range opts.Windows {
+		w.windows = append(w.windows, newWindow(dur))
+	}
+	return w
+}
+
+// admit returns the metric that values for the metric should be added to
+// according to the limits, and false if they should be dropped.
+func (s *Writer) admit(metric string) (string, bool) {
+	if s.limiter == nil {
+		return metric, true
+	}
+	return s.limiter.Admit(metric)
+}
+
+// LimitStats returns the number of metrics admitted by the limits since the
+// last Capture, and the stats for the n prefixes with the most rejected
+// values. It returns false if the Writer has no limits.
+func (s *Writer) LimitStats(n int) (
+	metrics int64, prefixes []PrefixStats, ok bool) {
+
+	if s.limiter == nil {
+		return 0, nil, false
+	}
+	metrics, prefixes = s.limiter.Stats(n)
+	return metrics, prefixes, true
+}
+
+// Params returns the params used to create the distributions for the metric.
+func (s *Writer) Params(metric string) dist.Params {
+	if len(s.rules) == 0 {
+		return s.params
+	}
+	selected := s.selected.Load().(*sync.Map)
+	if params, ok := selected.Load(metric); ok {
+		return params.(dist.Params)
+	}
+
+	params := s.params
+	for _, rule := range s.rules {
+		if rule.Match(metric) {
+			params = rule.Params
+			break
+		}
+	}
+	selected.Store(metric, params)
+	return params
+}
+
+// Windows returns the durations of the rolling windows kept by the Writer.
+func (s *Writer) Windows() []time.Duration {
+	durs := make([]time.Duration, 0, len(s.windows))
+	for _, win := range s.windows {
+		durs = append(durs, win.dur)
+	}
+	return durs
+}
+
 // Add adds the metric value to the current set of records. It will be
-// reflected in the distribution of the records returned by Capture. WARNING:
-// under some concurrent scenarios, this can lose updates.
+// reflected in the distribution of exactly one of the records returned by
+// Capture, even if Capture is called concurrently. If the metric is beyond
+// the Writer's limits, the value is dropped or added to the overflow metric.
 func (s *Writer) Add(ctx context.Context, metric string,
 	value float64, id []byte) {
 
@@ -51,8 +208,185 @@ func (s *Writer) Add(ctx context.Context, metric string,
 	if math.IsInf(value, 0) || math.IsNaN(value) {
 		return
 	}
+	metric, ok := s.admit(metric)
+	if !ok {
+		return
+	}
+
+	params := s.Params(metric)
+	p := s.acquirePage()
+	p.observe(params, metric, value, 1, id)
+	p.release()
+
+	s.eachWindow(func(p *page) { p.observe(params, metric, value, 1, id) })
+}
+
+// AddWeighted is like Add, except that the value is counted as weight
+// observations, as if Add was called weight times. Values with a weight that
+// is not positive are skipped.
+func (s *Writer) AddWeighted(ctx context.Context, metric string,
+	value float64, weight int64, id []byte) {
+
+	// skip problematic floating point values and weights
+	if math.IsInf(value, 0) || math.IsNaN(value) || weight <= 0 {
+		return
+	}
+	metric, ok := s.admit(metric)
+	if !ok {
+		return
+	}
+
+	params := s.Params(metric)
+	p := s.acquirePage()
+	p.observe(params, metric, value, weight, id)
+	p.release()
+
+	s.eachWindow(func(p *page) {
+		p.observe(params, metric, value, weight, id)
+	})
+}
+
+// Drop is the reason AddAt did not add a value.
+type Drop int
+
+const (
+	// NotDropped means the value was added.
+	NotDropped Drop = iota
+
+	// DropInvalid means the value was NaN or infinite.
+	DropInvalid
+
+	// DropLimited means the metric was rejected by the Writer's limits.
+	DropLimited
+
+	// DropLate means the time was before the start of the current set of
+	// records.
+	DropLate
+)
+
+// AddAt is like Add, except that the value is only added if the time is not
+// before the start of the current set of records. A zero time is never late.
+// It returns why the value was dropped, or NotDropped if it was added.
+func (s *Writer) AddAt(ctx context.Context, metric string, at time.Time,
+	value float64, id []byte) Drop {
+
+	// skip problematic floating point values
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return DropInvalid
+	}
+	metric, ok := s.admit(metric)
+	if !ok {
+		return DropLimited
+	}
+
+	params := s.Params(metric)
+	p := s.acquirePage()
+	if !at.IsZero() && at.Before(p.now) {
+		p.release()
+		return DropLate
+	}
+	p.observe(params, metric, value, 1, id)
+	p.release()
+
+	s.eachWindow(func(p *page) { p.observe(params, metric, value, 1, id) })
+	return NotDropped
+}
+
+// AddRecord merges the record, as if all of its observations were added with
+// Add. The record's distribution must be the same kind as the params for the
+// metric, and the distribution must support merging. The distribution,
+// observations, min and max along with their ids, sums and exemplars of the
+// record are merged, and if the record has no exact sums, neither does the
+// record for the metric. The times and merge count are not used.
+func (s *Writer) AddRecord(ctx context.Context, metric string,
+	rec Record) error {
+
+	metric, ok := s.admit(metric)
+	if !ok {
+		return errs.New("metric limit exceeded: %q", metric)
+	}
+
+	params := s.Params(metric)
+	if rec.Kind != params.Kind() {
+		return errs.New("can not merge %q record into %q distribution",
+			rec.Kind, params.Kind())
+	}
+	d, err := params.Unmarshal(rec.Distribution)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	return s.merge(params, metric, rec, d)
+}
+
+// AddDist merges the serialized distribution of the given kind, as if all of
+// its observations were added with Add. The min and max are estimated from
+// the distribution, and since the sums can not be, the record for the metric
+// no longer has exact sums.
+func (s *Writer) AddDist(ctx context.Context, metric string, kind string,
+	data []byte) error {
+
+	metric, ok := s.admit(metric)
+	if !ok {
+		return errs.New("metric limit exceeded: %q", metric)
+	}
+
+	params := s.Params(metric)
+	if kind != params.Kind() {
+		return errs.New("can not merge %q distribution into %q distribution",
+			kind, params.Kind())
+	}
+	d, err := params.Unmarshal(data)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	rec := Record{
+		Observations: d.Len(),
+		Min:          d.Query(0),
+		Max:          d.Query(1),
+	}
+	return s.merge(params, metric, rec, d)
+}
+
+// merge merges the record with the unmarshaled distribution into the current
+// page and every rolling window.
+func (s *Writer) merge(params dist.Params, metric string, rec Record,
+	d dist.Dist) error {
 
-	// load up the page pointer, allocating a fresh page if there isn't one.
+	p := s.acquirePage()
+	err := p.merge(params, metric, rec, d)
+	p.release()
+	if err != nil {
+		return err
+	}
+
+	s.eachWindow(func(p *page) { p.merge(params, metric, rec, d) })
+	return nil
+}
+
+// Queue implements the database.Sink interface by merging the marshaled
+// record in the data into the current set of records. The start and end
+// times are ignored. The callback, if any, is called before Queue returns.
+func (s *Writer) Queue(ctx context.Context, metric string, start, end int64,
+	data []byte, cb func(written bool, err error)) (err error) {
+
+	var rec Record
+	if err = rec.Unmarshal(data); err != nil {
+		err = errs.Wrap(err)
+	} else {
+		err = s.AddRecord(ctx, metric, rec)
+	}
+
+	if cb != nil {
+		cb(err == nil, err)
+	}
+	return err
+}
+
+// loadPage loads up the page pointer, allocating a fresh page if there isn't
+// one.
+func (s *Writer) loadPage() *page {
 	var pi unsafe.Pointer
 	for {
 		pi = atomic.LoadPointer(&s.page)
@@ -67,49 +401,67 @@ func (s *Writer) Add(ctx context.Context, metric string,
 			break
 		}
 	}
-	p := (*page)(pi)
+	return (*page)(pi)
+}
 
-	// TODO(jeff): there is a race here where we can lose writes: if someone
-	// is calling Capture and that finishes and sets a new page, a call to
-	// Add may use an agg on a page that will no longer be Captured.
-	// Callers may work around this by ensuring no concurrent calls to Add
-	// and Capture, but the data loss is probably acceptable.
+// SetStart sets the start time of the current set of records if nothing has
+// been added to the Writer yet, and reports if it did. It is useful for
+// restoring records that were being aggregated before a restart.
+func (s *Writer) SetStart(start time.Time) bool {
+	return atomic.CompareAndSwapPointer(&s.page, nil,
+		unsafe.Pointer(newPage(start)))
+}
 
-	ai, ok := p.m.Load(metric)
-	if !ok {
-		// we use LoadOrStore here to avoid a mutex at the cost of wasted
-		// allocations for losers during contention.
-		ai, _ = p.m.LoadOrStore(metric, newAgg(s.params, p.now))
-	}
-	a := ai.(*agg)
+// acquirePage returns the current page, counting a write in flight on it so
+// that Capture waits for the write before it reads the page. The caller must
+// call release on the page when the write is done.
+func (s *Writer) acquirePage() *page {
+	for {
+		p := s.loadPage()
+		atomic.AddInt64(&p.refs, 1)
 
-	a.Observe(value, id)
+		// if the page is still current, any Capture that swaps it out must
+		// happen after our increment, and so it will wait for our release.
+		// otherwise, the page may already be draining, so we try again.
+		if atomic.LoadPointer(&s.page) == unsafe.Pointer(p) {
+			return p
+		}
+		p.release()
+	}
 }
 
 // Capture clears out current set of records for future Add calls and
-// calls the provided function with every record. You must not hold on to
-// any fields of the record after the callback returns.
+// calls the provided function with every record. Every value added before
+// Capture is called is included in exactly one captured record. Only the
+// flush window is captured, and the rolling windows are left alone. You must
+// not hold on to any fields of the record after the callback returns, and the
+// callback must not call Capture.
 func (s *Writer) Capture(ctx context.Context,
 	fn func(ctx context.Context, metric string, rec Record) bool) {
 
-	// read the page out. capture clears out the page so we will be setting
-	// it to a new page that we allocate so that the timestamps line up
-	// perfectly.
-	pi := atomic.LoadPointer(&s.page)
-	if pi == nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// capture clears out the page so we swap in a new page that we allocate
+	// so that the timestamps line up perfectly.
+	if atomic.LoadPointer(&s.page) == nil {
 		return
 	}
-	p := (*page)(pi)
 	now := time.Now()
+	p := (*page)(atomic.SwapPointer(&s.page, unsafe.Pointer(newPage(now))))
 
-	// swap it out with a new page starting at the capture time. if we are
-	// unable to do this, some other call must be ranging on the page, and
-	// so we don't want to also range over it.
-	new_pi := unsafe.Pointer(newPage(now))
-	if !atomic.CompareAndSwapPointer(&s.page, pi, new_pi) {
-		return
+	// the limits count the metrics in each page, so start them over, and
+	// forget the params selected for metrics that may not come back.
+	if s.limiter != nil {
+		s.limiter.Reset()
+	}
+	if len(s.rules) > 0 {
+		s.selected.Store(new(sync.Map))
 	}
 
+	// wait for any writes that acquired the old page before the swap.
+	p.drain()
+
 	// iterate it
 	var buf []byte
 	p.m.Range(func(key, ai interface{}) (ok bool) {
@@ -119,25 +471,102 @@ func (s *Writer) Capture(ctx context.Context,
 	})
 }
 
-// Iterate calls the provided function with every record. You must not hold on
-// to any fields of the record after the callback returns.
-func (s *Writer) Iterate(ctx context.Context,
-	fn func(ctx context.Context, metric string, rec Record) bool) {
+// Iterate calls the provided function with the record of every metric in the
+// window with the duration. A zero duration is the flush window: the records
+// that would be returned by Capture. Any other duration must be one of the
+// rolling windows, whose records start at the earliest step in the window.
+// You must not hold on to any fields of the record after the callback
+// returns.
+func (s *Writer) Iterate(ctx context.Context, window time.Duration,
+	fn func(ctx context.Context, metric string, rec Record) bool) error {
 
-	// read the page out. iterate does not clear out the page so we just need
-	// to read and if we have no page, we're done.
-	pi := atomic.LoadPointer(&s.page)
-	if pi == nil {
-		return
+	if window == 0 {
+		// iterate does not clear out the page so we just need to read and
+		// if we have no page, we're done.
+		pi := atomic.LoadPointer(&s.page)
+		if pi == nil {
+			return nil
+		}
+		p := (*page)(pi)
+		now := time.Now()
+
+		var buf []byte
+		p.m.Range(func(key, ai interface{}) (ok bool) {
+			var rec Record
+			buf, rec = ai.(*agg).Finish(buf, now)
+			return fn(ctx, key.(string), rec)
+		})
+		return nil
+	}
+
+	win, err := s.findWindow(window)
+	if err != nil {
+		return err
 	}
-	p := (*page)(pi)
 	now := time.Now()
+	pages, start := win.pages(now)
+
+	// collect the metrics in any page. we merge them one at a time so that
+	// only one merged distribution is alive at once.
+	metrics := make(map[string]struct{})
+	for _, p := range pages {
+		p.m.Range(func(key, ai interface{}) bool {
+			metrics[key.(string)] = struct{}{}
+			return true
+		})
+	}
 
-	// iterate it
 	var buf []byte
-	p.m.Range(func(key, ai interface{}) (ok bool) {
+	for metric := range metrics {
+		a, err := mergePages(s.Params(metric), metric, pages, start)
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			continue
+		}
+
 		var rec Record
-		buf, rec = ai.(*agg).Finish(buf, now)
-		return fn(ctx, key.(string), rec)
-	})
+		buf, rec = a.Finish(buf, now)
+		if !fn(ctx, metric, rec) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Load returns the record for the metric in the window with the duration,
+// where a zero duration is the flush window, like Iterate. It returns false
+// if the metric has no values in the window.
+func (s *Writer) Load(ctx context.Context, metric string,
+	window time.Duration) (rec Record, ok bool, err error) {
+
+	if window == 0 {
+		pi := atomic.LoadPointer(&s.page)
+		if pi == nil {
+			return Record{}, false, nil
+		}
+		ai, ok := (*page)(pi).m.Load(metric)
+		if !ok {
+			return Record{}, false, nil
+		}
+		_, rec = ai.(*agg).Finish(nil, time.Now())
+		return rec, true, nil
+	}
+
+	win, err := s.findWindow(window)
+	if err != nil {
+		return Record{}, false, err
+	}
+	now := time.Now()
+	pages, start := win.pages(now)
+
+	a, err := mergePages(s.Params(metric), metric, pages, start)
+	if err != nil || a == nil {
+		return Record{}, false, err
+	}
+
+	_, rec = a.Finish(nil, now)
+	return rec, true, nil
 }
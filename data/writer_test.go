@@ -5,8 +5,12 @@ package data
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/vivint/rothko/internal/assert"
 )
@@ -22,10 +26,11 @@ func TestWriter(t *testing.T) {
 	w.Add(ctx, "4", 4, nil)
 
 	got := make(map[string]bool)
-	w.Iterate(ctx, func(ctx context.Context, metric string, rec Record) bool {
-		got[metric] = true
-		return true
-	})
+	assert.NoError(t, w.Iterate(ctx, 0,
+		func(ctx context.Context, metric string, rec Record) bool {
+			got[metric] = true
+			return true
+		}))
 
 	assert.That(t, got["1"])
 	assert.That(t, got["2"])
@@ -52,6 +57,189 @@ func TestWriter(t *testing.T) {
 	assert.That(t, len(got) == 0)
 }
 
+func TestWriterAddAt(t *testing.T) {
+	ctx := context.Background()
+
+	w := NewWriter(fakeParams{})
+	now := time.Now()
+
+	assert.Equal(t, w.AddAt(ctx, "1", now.Add(time.Second), 1, nil),
+		NotDropped)
+	assert.Equal(t, w.AddAt(ctx, "2", now.Add(-time.Second), 2, nil),
+		DropLate)
+	assert.Equal(t, w.AddAt(ctx, "3", now.Add(time.Second), math.NaN(), nil),
+		DropInvalid)
+
+	got := make(map[string]bool)
+	w.Capture(ctx, func(ctx context.Context, metric string, rec Record) bool {
+		got[metric] = true
+		return true
+	})
+
+	assert.DeepEqual(t, got, map[string]bool{"1": true})
+}
+
+func TestWriterAddWeighted(t *testing.T) {
+	ctx := context.Background()
+
+	w := NewWriter(fakeParams{})
+	w.AddWeighted(ctx, "1", 1, 10, nil)
+	w.AddWeighted(ctx, "1", 2, 0, nil)
+	w.AddWeighted(ctx, "2", 2, -1, nil)
+	w.AddWeighted(ctx, "3", math.Inf(1), 10, nil)
+
+	got := make(map[string]int64)
+	w.Capture(ctx, func(ctx context.Context, metric string, rec Record) bool {
+		got[metric] = rec.Observations
+		return true
+	})
+
+	assert.DeepEqual(t, got, map[string]int64{"1": 10})
+}
+
+func TestWriterConcurrentCapture(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		adders   = 8
+		adds     = 10000
+		metrics  = 10
+		captures = 4
+	)
+
+	w := NewWriter(fakeParams{})
+
+	var captured int64
+	capture := func() {
+		w.Capture(ctx, func(ctx context.Context, metric string,
+			rec Record) bool {
+
+			atomic.AddInt64(&captured, rec.Observations)
+			return true
+		})
+	}
+
+	var adding, capturing sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < captures; i++ {
+		capturing.Add(1)
+		go func() {
+			defer capturing.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					capture()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < adders; i++ {
+		adding.Add(1)
+		go func(i int) {
+			defer adding.Done()
+			for j := 0; j < adds; j++ {
+				metric := fmt.Sprint((i + j) % metrics)
+				switch j % 3 {
+				case 0:
+					w.Add(ctx, metric, float64(j), nil)
+				case 1:
+					w.AddWeighted(ctx, metric, float64(j), 2, nil)
+				case 2:
+					w.AddAt(ctx, metric, time.Now().Add(time.Hour),
+						float64(j), nil)
+				}
+			}
+		}(i)
+	}
+
+	adding.Wait()
+	close(done)
+	capturing.Wait()
+	capture()
+
+	expected := int64(0)
+	for j := 0; j < adds; j++ {
+		expected++
+		if j%3 == 1 {
+			expected++
+		}
+	}
+	assert.Equal(t, atomic.LoadInt64(&captured), expected*adders)
+}
+
+func TestWriterRules(t *testing.T) {
+	ctx := context.Background()
+
+	other := otherParams{}
+	w := NewWriterWithOptions(fakeParams{}, WriterOptions{
+		Rules: []Rule{{
+			Match:  func(metric string) bool { return metric == "other" },
+			Params: other,
+		}},
+	})
+	assert.Equal(t, w.Params("other").Kind(), "other")
+	assert.Equal(t, w.Params("metric").Kind(), "fake")
+
+	w.Add(ctx, "other", 1, nil)
+	w.Add(ctx, "metric", 1, nil)
+
+	got := make(map[string]string)
+	w.Capture(ctx, func(ctx context.Context, metric string, rec Record) bool {
+		got[metric] = rec.Kind
+		return true
+	})
+
+	assert.DeepEqual(t, got, map[string]string{
+		"other":  "other",
+		"metric": "fake",
+	})
+
+	// the selected params are forgotten by the capture
+	_, ok := w.selected.Load().(*sync.Map).Load("other")
+	assert.That(t, !ok)
+}
+
+func TestWriterQueue(t *testing.T) {
+	ctx := context.Background()
+
+	w := NewWriter(fakeParams{})
+	w.Add(ctx, "1", 5, nil)
+
+	data, err := (&Record{
+		Observations: 3,
+		Min:          1,
+		Max:          2,
+		Kind:         "fake",
+	}).Marshal()
+	assert.NoError(t, err)
+
+	var written bool
+	assert.NoError(t, w.Queue(ctx, "1", 0, 0, data,
+		func(ok bool, err error) { written = ok }))
+	assert.That(t, written)
+	assert.NoError(t, w.AddDist(ctx, "2", "fake", nil))
+
+	data, err = (&Record{Kind: "other"}).Marshal()
+	assert.NoError(t, err)
+	assert.Error(t, w.Queue(ctx, "1", 0, 0, data, nil))
+	assert.Error(t, w.AddDist(ctx, "2", "other", nil))
+
+	got := make(map[string]Record)
+	w.Capture(ctx, func(ctx context.Context, metric string, rec Record) bool {
+		got[metric] = rec
+		return true
+	})
+
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got["1"].Observations, int64(4))
+	assert.Equal(t, got["1"].Min, float64(1))
+	assert.Equal(t, got["1"].Max, float64(5))
+}
+
 func BenchmarkWriter(b *testing.B) {
 	ctx := context.Background()
 
@@ -93,7 +281,7 @@ func BenchmarkWriter(b *testing.B) {
 		b.ResetTimer()
 
 		for i := 0; i < b.N; i++ {
-			w.Iterate(ctx, iterate)
+			w.Iterate(ctx, 0, iterate)
 		}
 
 		b.SetBytes(bytes)
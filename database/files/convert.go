@@ -2,13 +2,32 @@
 
 package files
 
-import "strconv"
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// tagsFile is the name of the file holding the graphite style tags of the
+// metric stored in a tags directory.
+const tagsFile = "tags"
 
 // metricToDir takes a metric and converts it to a path on disk. It replaces
 // dots with path separators, where when encountering a group of dots, only the
 // first dot becomes a separator. Path characters are replaced with urlencoded
-// values.
+// values. Any graphite style tags starting at the first semicolon are not
+// put in the path, since they can be long and contain anything. Instead, the
+// metric is stored in a tags directory under the one for the name, named by
+// a semicolon and a hash of the tags, that holds the tags in its tagsFile.
 func metricToDir(buf []byte, metric string) []byte {
+	tags := ""
+	if index := strings.IndexByte(metric, ';'); index >= 0 {
+		metric, tags = metric[:index], metric[index:]
+	}
+	start := len(buf)
+
 	non_dots := false
 	dots := 0
 	for i := 0; i < len(metric); i++ {
@@ -48,9 +67,18 @@ func metricToDir(buf []byte, metric string) []byte {
 		}
 	}
 
+	if tags != "" {
+		if len(buf) > start {
+			buf = append(buf, '/')
+		}
+		buf = appendTagsDir(buf, tags)
+	}
+
 	return buf
 }
 
+// dirToMetric undoes the transformation done by metricToDir for metrics
+// without tags.
 func dirToMetric(buf, dir []byte) ([]byte, error) {
 	last_dot := false
 	all_dots := false
@@ -103,6 +131,29 @@ func dirToMetric(buf, dir []byte) ([]byte, error) {
 	return buf, nil
 }
 
+// appendTagsDir appends the name of the tags directory for the tags.
+func appendTagsDir(buf []byte, tags string) []byte {
+	sum := sha256.Sum256([]byte(tags))
+	var name [32]byte
+	hex.Encode(name[:], sum[:16])
+
+	buf = append(buf, ';')
+	return append(buf, name[:]...)
+}
+
+// splitTagsDir splits the tags directory off of the end of the dir, returning
+// false if it does not end in one.
+func splitTagsDir(dir []byte) (name []byte, ok bool) {
+	index := bytes.LastIndexByte(dir, '/')
+	if index+1 >= len(dir) || dir[index+1] != ';' {
+		return dir, false
+	}
+	if index == -1 {
+		return dir[:0], true
+	}
+	return dir[:index], true
+}
+
 // metricToPath selects the data file number out of the directory identified by
 // the metric with metricToDir.
 func metricToPath(buf []byte, metric string, num int) []byte {
@@ -52,6 +52,36 @@ func TestConvert(t *testing.T) {
 		assert.Equal(t, f(`%2e%2e%2e/foo/bar`), `...foo.bar`)
 	})
 
+	t.Run("TagsDir", func(t *testing.T) {
+		f := func(metric string) string {
+			return string(metricToDir(nil, metric))
+		}
+		split := func(dir string) (string, bool) {
+			name, ok := splitTagsDir([]byte(dir))
+			return string(name), ok
+		}
+
+		// the tags never show up in the path.
+		hash := string(appendTagsDir(nil, ";a=b.c;d=e/f"))
+		assert.Equal(t, len(hash), 33)
+		assert.Equal(t, f(`foo.bar;a=b.c;d=e/f`), `foo/bar/`+hash)
+		assert.Equal(t, f(`foo.;a=b.c;d=e/f`), `foo/%2e/`+hash)
+		assert.Equal(t, f(`;a=b.c;d=e/f`), hash)
+		assert.That(t, f(`foo;a=b`) != f(`foo;a=c`))
+
+		name, ok := split(`foo/bar/` + hash)
+		assert.That(t, ok)
+		assert.Equal(t, name, `foo/bar`)
+		name, ok = split(hash)
+		assert.That(t, ok)
+		assert.Equal(t, name, ``)
+		name, ok = split(`foo/bar`)
+		assert.That(t, !ok)
+		assert.Equal(t, name, `foo/bar`)
+		_, ok = split(``)
+		assert.That(t, !ok)
+	})
+
 	t.Run("MetricToPath", func(t *testing.T) {
 		f := func(metric string, num int) string {
 			return string(metricToPath(nil, metric, num))
@@ -5,6 +5,9 @@ package files
 import (
 	"bytes"
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"syscall"
 
 	"github.com/vivint/rothko/database"
@@ -232,11 +235,25 @@ func (dp *dbPopulator) populate(ctx context.Context) (err error) {
 				continue
 			}
 
-			// we have a metric, so add it to out reusing the dp.namebuf space
-			dp.namebuf, err = dirToMetric(dp.namebuf[:0], dp.dirbuf)
+			// we have a metric, so add it to out reusing the dp.namebuf space.
+			// metrics with tags are in a tags directory holding their tags.
+			dir, has_tags := splitTagsDir(dp.dirbuf)
+			dp.namebuf, err = dirToMetric(dp.namebuf[:0], dir)
 			if err != nil {
 				return err
 			}
+			if has_tags {
+				tags, err := ioutil.ReadFile(filepath.Join(
+					dp.dir, string(dp.dirbuf), tagsFile))
+				if os.IsNotExist(err) {
+					// without the tags the metric can not be named.
+					added = true
+					continue
+				} else if err != nil {
+					return Error.Wrap(err)
+				}
+				dp.namebuf = append(dp.namebuf, tags...)
+			}
 			dp.out.Add(string(dp.namebuf))
 
 			// mark it added so we skip over adding other .data files
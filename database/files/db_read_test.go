@@ -32,6 +32,46 @@ func TestDBRead(t *testing.T) {
 		assert.NoError(t, err)
 		assert.DeepEqual(t, names, expected)
 	})
+
+	t.Run("MetricsTags", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		db, cleanup := newTestDB(t, Options{
+			Size:  1024,
+			Cap:   10,
+			Files: 10,
+		})
+		defer cleanup()
+		go db.Run(ctx)
+
+		expected := map[string]struct{}{
+			"cpu.load":                     {},
+			"cpu.load;host=web1":           {},
+			"cpu.load;dc=east;host=web1.a": {},
+			";host=web/2":                  {},
+		}
+		done := make(chan error, len(expected))
+		for name := range expected {
+			db.Queue(ctx, name, 0, 1, make([]byte, 10),
+				func(ok bool, err error) { done <- err })
+		}
+		for range expected {
+			assert.NoError(t, <-done)
+		}
+
+		// read the names back off of disk with a fresh database.
+		db = New(db.dir, db.opts)
+		assert.NoError(t, db.PopulateMetrics(ctx))
+
+		names := make(map[string]struct{})
+		err := db.Metrics(ctx, func(name string) (ok bool, err error) {
+			names[name] = struct{}{}
+			return true, nil
+		})
+		assert.NoError(t, err)
+		assert.DeepEqual(t, names, expected)
+	})
 }
 
 func BenchmarkDBRead(b *testing.B) {
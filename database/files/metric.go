@@ -6,7 +6,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -91,6 +93,9 @@ func newMetric(ctx context.Context, opts metricOptions) (*metric, error) {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, Error.Wrap(err)
 		}
+		if err := writeTags(dir, opts.name); err != nil {
+			return nil, err
+		}
 		dh, err = os.Open(dir)
 	}
 	if err != nil {
@@ -160,6 +165,18 @@ func newMetric(ctx context.Context, opts metricOptions) (*metric, error) {
 	}, nil
 }
 
+// writeTags writes the graphite style tags of the metric, if it has any, to
+// the tagsFile in the directory, so that the metric name can be recovered.
+func writeTags(dir, name string) error {
+	index := strings.IndexByte(name, ';')
+	if index == -1 {
+		return nil
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, tagsFile),
+		[]byte(name[index:]), 0644)
+	return Error.Wrap(err)
+}
+
 // filenameAt returns the filename for the data file at the index.
 func (m *metric) filenameAt(index int) string {
 	if path, ok := m.interned[index]; ok {
@@ -21,10 +21,23 @@ type Dist interface {
 	// Observe a value.
 	Observe(val float64)
 
+	// ObserveWeighted observes a value as if Observe was called weight
+	// times. Weights that are not positive are ignored.
+	ObserveWeighted(val float64, weight int64)
+
 	// Marshal by appending to the provided buf.
 	Marshal(buf []byte) []byte
 }
 
+// Merger is implemented by Dists that can merge in another Dist. Merging is
+// only expected to work for Dists of the same kind, and possibly only if they
+// were made by the same Params.
+type Merger interface {
+	// Merge adds all of the observations of the other Dist. It returns an
+	// error if the other Dist can not be merged.
+	Merge(other Dist) error
+}
+
 // Params represents a way to create Dists. An implementation must cope with
 // being created with possibly no configuration if coming from the registry.
 // New is allowed to error in this case, but Unmarshal and Kind should not.
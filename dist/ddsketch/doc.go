@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package ddsketch provides a DDSketch distribution, which answers quantile
+// queries with a bounded relative error.
+package ddsketch
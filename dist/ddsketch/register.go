@@ -0,0 +1,31 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package ddsketch
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/registry"
+)
+
+func init() {
+	registry.RegisterDistribution("ddsketch", registry.DistributionMakerFunc(
+		func(ctx context.Context, config interface{}) (dist.Params, error) {
+			if config == nil {
+				return Params{}, nil
+			}
+
+			a := typeassert.A(config)
+			params := Params{
+				RelativeAccuracy: a.I("relative_accuracy").Float64(),
+				BinLimit:         int(a.I("bin_limit").Int64()),
+			}
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			return params, nil
+		}))
+}
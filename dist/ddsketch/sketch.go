@@ -0,0 +1,401 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package ddsketch
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
+
+// DefaultBinLimit is the bin limit used if the Params do not specify one.
+const DefaultBinLimit = 2048
+
+// MinRelativeAccuracy is the smallest relative accuracy allowed. It keeps the
+// bin index of every finite value well within an int32.
+const MinRelativeAccuracy = 1e-6
+
+// version is the first byte of every marshaled sketch.
+const version = 1
+
+// Params implements dist.Params for a DDSketch distribution.
+type Params struct {
+	// RelativeAccuracy is the relative error of any quantile query, and must
+	// be in [MinRelativeAccuracy, 1). For example, 0.01 means queries are
+	// within 1% of the true value.
+	RelativeAccuracy float64
+
+	// BinLimit is the most bins kept for each of the positive and negative
+	// values. If more would be needed, the bins for the smallest magnitudes
+	// are collapsed, losing accuracy for the lowest quantiles first. If 0,
+	// DefaultBinLimit is used.
+	BinLimit int
+}
+
+// Kind returns the DDSketch distribution kind.
+func (p Params) Kind() string {
+	return "ddsketch"
+}
+
+// New returns a new Sketch as a dist.Dist.
+func (p Params) New() (dist.Dist, error) {
+	if p.RelativeAccuracy == 0 {
+		return nil, errs.New("New called on zero value Params")
+	}
+	if !validAccuracy(p.RelativeAccuracy) {
+		return nil, errs.New("invalid relative accuracy: %v",
+			p.RelativeAccuracy)
+	}
+	if p.BinLimit < 0 {
+		return nil, errs.New("invalid bin limit: %v", p.BinLimit)
+	}
+	return newSketch(p.RelativeAccuracy, p.BinLimit), nil
+}
+
+// validAccuracy returns true if the relative accuracy is allowed.
+func validAccuracy(accuracy float64) bool {
+	return accuracy >= MinRelativeAccuracy && accuracy < 1
+}
+
+// Unmarshal loads a dist.Dist out of some bytes.
+func (p Params) Unmarshal(data []byte) (dist.Dist, error) {
+	return unmarshalSketch(data)
+}
+
+//
+// Sketch
+//
+
+// Sketch implements dist.Dist for a DDSketch. Values are counted in bins
+// whose bounds grow geometrically, so that every value in a bin is within
+// the relative accuracy of the bin's representative value.
+type Sketch struct {
+	accuracy float64
+	limit    int
+	gamma    float64
+	log      float64
+
+	pos   store
+	neg   store
+	zero  uint64
+	count uint64
+	min   float64
+	max   float64
+}
+
+// newSketch constructs a Sketch with the relative accuracy and bin limit.
+func newSketch(accuracy float64, limit int) *Sketch {
+	if limit == 0 {
+		limit = DefaultBinLimit
+	}
+	gamma := (1 + accuracy) / (1 - accuracy)
+	return &Sketch{
+		accuracy: accuracy,
+		limit:    limit,
+		gamma:    gamma,
+		log:      math.Log(gamma),
+	}
+}
+
+// Kind returns the string "ddsketch".
+func (*Sketch) Kind() string {
+	return "ddsketch"
+}
+
+// Observe adds the value to the sketch.
+func (s *Sketch) Observe(val float64) {
+	s.ObserveWeighted(val, 1)
+}
+
+// ObserveWeighted adds the value to the sketch weight times. Values that are
+// NaN or infinite are ignored.
+func (s *Sketch) ObserveWeighted(val float64, weight int64) {
+	if weight <= 0 || math.IsNaN(val) || math.IsInf(val, 0) {
+		return
+	}
+
+	switch {
+	case val > 0:
+		s.pos.add(s.index(val), uint64(weight), s.limit)
+	case val < 0:
+		s.neg.add(s.index(-val), uint64(weight), s.limit)
+	default:
+		s.zero += uint64(weight)
+	}
+
+	if s.count == 0 || val < s.min {
+		s.min = val
+	}
+	if s.count == 0 || val > s.max {
+		s.max = val
+	}
+	s.count += uint64(weight)
+}
+
+// Merge merges the other sketch into this one. The other sketch must be a
+// Sketch with the same relative accuracy.
+func (s *Sketch) Merge(other dist.Dist) error {
+	o, ok := other.(*Sketch)
+	if !ok {
+		return errs.New("can not merge %q into ddsketch", other.Kind())
+	}
+	if o.accuracy != s.accuracy {
+		return errs.New("can not merge ddsketch with relative accuracy %v "+
+			"into one with %v", o.accuracy, s.accuracy)
+	}
+	if o.count == 0 {
+		return nil
+	}
+
+	s.pos.merge(&o.pos, s.limit)
+	s.neg.merge(&o.neg, s.limit)
+	s.zero += o.zero
+
+	if s.count == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	if s.count == 0 || o.max > s.max {
+		s.max = o.max
+	}
+	s.count += o.count
+	return nil
+}
+
+// index returns the index of the bin for the positive value.
+func (s *Sketch) index(val float64) int {
+	return int(math.Ceil(math.Log(val) / s.log))
+}
+
+// indexes returns the lowest and highest bin indexes that a finite, non-zero
+// magnitude maps to.
+func (s *Sketch) indexes() (lo, hi int) {
+	return s.index(math.SmallestNonzeroFloat64), s.index(math.MaxFloat64)
+}
+
+// value returns the representative value of the bin with the index.
+func (s *Sketch) value(index int) float64 {
+	return 2 * math.Exp(float64(index)*s.log) / (1 + s.gamma)
+}
+
+// Bins calls fn with the representative value and count of every non-empty
+// bin in increasing order of value, until it returns false.
+func (s *Sketch) Bins(fn func(value float64, count uint64) bool) {
+	for i := len(s.neg.counts) - 1; i >= 0; i-- {
+		if count := s.neg.counts[i]; count > 0 {
+			if !fn(-s.value(s.neg.offset+i), count) {
+				return
+			}
+		}
+	}
+	if s.zero > 0 {
+		if !fn(0, s.zero) {
+			return
+		}
+	}
+	for i, count := range s.pos.counts {
+		if count > 0 {
+			if !fn(s.value(s.pos.offset+i), count) {
+				return
+			}
+		}
+	}
+}
+
+// Query returns the approximate x'th quantile.
+func (s *Sketch) Query(x float64) (out float64) {
+	if s.count == 0 {
+		return 0
+	}
+	if x <= 0 {
+		return s.min
+	}
+	if x >= 1 {
+		return s.max
+	}
+
+	rank := x * float64(s.count-1)
+	out = s.max
+	var sum uint64
+	s.Bins(func(value float64, count uint64) bool {
+		sum += count
+		if float64(sum) > rank {
+			out = value
+			return false
+		}
+		return true
+	})
+
+	return math.Max(s.min, math.Min(s.max, out))
+}
+
+// CDF returns the approximate CDF at the value x. Values in the same bin as x
+// are counted as being half below it.
+func (s *Sketch) CDF(x float64) float64 {
+	if s.count == 0 || x < s.min {
+		return 0
+	}
+	if x >= s.max {
+		return 1
+	}
+
+	var target float64
+	switch {
+	case x > 0:
+		target = s.value(s.index(x))
+	case x < 0:
+		target = -s.value(s.index(-x))
+	}
+
+	var below float64
+	s.Bins(func(value float64, count uint64) bool {
+		switch {
+		case value < target:
+			below += float64(count)
+			return true
+		case value == target:
+			below += float64(count) / 2
+		}
+		return false
+	})
+
+	return below / float64(s.count)
+}
+
+// Len returns how many items were added to the sketch.
+func (s *Sketch) Len() int64 {
+	return int64(s.count)
+}
+
+// Marshal appends a byte form of the sketch to the provided buffer.
+func (s *Sketch) Marshal(buf []byte) []byte {
+	buf = append(buf, version)
+	buf = appendFloat64(buf, s.accuracy)
+	buf = binary.AppendUvarint(buf, uint64(s.limit))
+	buf = appendFloat64(buf, s.min)
+	buf = appendFloat64(buf, s.max)
+	buf = binary.AppendUvarint(buf, s.zero)
+	buf = appendStore(buf, &s.pos)
+	buf = appendStore(buf, &s.neg)
+	return buf
+}
+
+// appendFloat64 appends the bits of the float in little endian order.
+func appendFloat64(buf []byte, val float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(val))
+}
+
+// appendStore appends the offset, the number of bins, and the bins.
+func appendStore(buf []byte, st *store) []byte {
+	buf = binary.AppendVarint(buf, int64(st.offset))
+	buf = binary.AppendUvarint(buf, uint64(len(st.counts)))
+	for _, count := range st.counts {
+		buf = binary.AppendUvarint(buf, count)
+	}
+	return buf
+}
+
+// unmarshalSketch parses a sketch in the form that Marshal writes.
+func unmarshalSketch(data []byte) (*Sketch, error) {
+	r := reader{buf: data}
+	if r.byte() != version {
+		return nil, errs.New("invalid ddsketch version")
+	}
+	accuracy := r.float64()
+	limit := r.uvarint()
+	if r.err == nil && !validAccuracy(accuracy) {
+		return nil, errs.New("invalid relative accuracy: %v", accuracy)
+	}
+	if limit > math.MaxInt32 {
+		return nil, errs.New("invalid bin limit: %v", limit)
+	}
+
+	s := newSketch(accuracy, int(limit))
+	s.min = r.float64()
+	s.max = r.float64()
+	s.zero = r.uvarint()
+	s.count = s.zero
+	// every bin must be one that a finite value could map to, which also
+	// keeps the span of bins between two stores from overflowing.
+	lo, hi := s.indexes()
+	for _, st := range []*store{&s.pos, &s.neg} {
+		offset := r.varint()
+		bins := r.uvarint()
+		if bins > uint64(len(r.buf)) || bins > uint64(s.limit) {
+			return nil, errs.New("invalid ddsketch bin count: %v", bins)
+		}
+		if r.err == nil && (offset < int64(lo) ||
+			offset > int64(hi)-int64(bins)+1) {
+
+			return nil, errs.New("invalid ddsketch offset: %v", offset)
+		}
+		st.offset = int(offset)
+		st.counts = make([]uint64, bins)
+		for i := range st.counts {
+			st.counts[i] = r.uvarint()
+			s.count += st.counts[i]
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) > 0 {
+		return nil, errs.New("trailing data after ddsketch")
+	}
+	return s, nil
+}
+
+// reader reads values out of a buffer, remembering the first error.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errs.New("truncated ddsketch")
+	}
+	r.buf = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) float64() float64 {
+	if len(r.buf) < 8 {
+		r.fail()
+		return 0
+	}
+	val := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return val
+}
+
+func (r *reader) uvarint() uint64 {
+	val, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return val
+}
+
+func (r *reader) varint() int64 {
+	val, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return val
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package ddsketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func newTestSketch(t *testing.T, params Params) *Sketch {
+	d, err := params.New()
+	assert.NoError(t, err)
+	return d.(*Sketch)
+}
+
+func within(t *testing.T, got, want, accuracy float64) {
+	t.Helper()
+	if math.Abs(got-want) > accuracy*math.Abs(want)+1e-12 {
+		t.Fatalf("got %v, want %v within %v", got, want, accuracy)
+	}
+}
+
+func TestSketch(t *testing.T) {
+	params := Params{RelativeAccuracy: 0.01}
+	s := newTestSketch(t, params)
+
+	rng := rand.New(rand.NewSource(1))
+	var vals []float64
+	for i := 0; i < 10000; i++ {
+		val := rng.ExpFloat64() * 100
+		if i%10 == 0 {
+			val = -val
+		}
+		if i%100 == 0 {
+			val = 0
+		}
+		vals = append(vals, val)
+		s.Observe(val)
+	}
+	sort.Float64s(vals)
+
+	assert.Equal(t, s.Len(), int64(len(vals)))
+	assert.Equal(t, s.Query(0), vals[0])
+	assert.Equal(t, s.Query(1), vals[len(vals)-1])
+	for _, q := range []float64{0.01, 0.05, 0.1, 0.25, 0.5, 0.9, 0.99, 0.999} {
+		want := vals[int(q*float64(len(vals)-1))]
+		within(t, s.Query(q), want, params.RelativeAccuracy)
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		val := vals[int(q*float64(len(vals)))]
+		if cdf := s.CDF(val); math.Abs(cdf-q) > 0.01 {
+			t.Fatalf("cdf(%v) = %v, want %v", val, cdf, q)
+		}
+	}
+	assert.Equal(t, s.CDF(vals[0]-1), 0.0)
+	assert.Equal(t, s.CDF(vals[len(vals)-1]), 1.0)
+}
+
+func TestSketchMarshal(t *testing.T) {
+	params := Params{RelativeAccuracy: 0.02, BinLimit: 100}
+	s := newTestSketch(t, params)
+	for i := -100; i <= 1000; i++ {
+		s.ObserveWeighted(float64(i), int64(i%3+1))
+	}
+	s.Observe(math.NaN())
+	s.ObserveWeighted(1, 0)
+
+	data := s.Marshal(nil)
+	got, err := Params{}.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, got, s)
+	assert.DeepEqual(t, got.Marshal(nil), data)
+
+	for i := range data {
+		_, err := Params{}.Unmarshal(data[:i])
+		assert.Error(t, err)
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	params := Params{RelativeAccuracy: 0.01}
+	a, b, all := newTestSketch(t, params), newTestSketch(t, params),
+		newTestSketch(t, params)
+
+	for i := 0; i < 1000; i++ {
+		a.Observe(float64(i))
+		b.Observe(float64(i) * 1000)
+		all.Observe(float64(i))
+		all.Observe(float64(i) * 1000)
+	}
+
+	assert.NoError(t, a.Merge(b))
+	assert.Equal(t, a.Len(), all.Len())
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		assert.Equal(t, a.Query(q), all.Query(q))
+	}
+
+	other := newTestSketch(t, Params{RelativeAccuracy: 0.05})
+	assert.Error(t, a.Merge(other))
+}
+
+func TestSketchBinLimit(t *testing.T) {
+	s := newTestSketch(t, Params{RelativeAccuracy: 0.01, BinLimit: 10})
+	for i := 1; i <= 1000; i++ {
+		s.Observe(float64(i))
+	}
+
+	assert.That(t, len(s.pos.counts) <= 10)
+	assert.Equal(t, s.Len(), int64(1000))
+	assert.Equal(t, s.Query(0), 1.0)
+	within(t, s.Query(0.999), 999, 0.01)
+}
+
+func TestSketchUnmarshalOffsets(t *testing.T) {
+	params := Params{RelativeAccuracy: 0.01}
+	s := newTestSketch(t, params)
+	s.Observe(math.SmallestNonzeroFloat64)
+	s.Observe(math.MaxFloat64)
+	s.Observe(-math.MaxFloat64)
+
+	_, err := Params{}.Unmarshal(s.Marshal(nil))
+	assert.NoError(t, err)
+
+	// offsets that no finite value maps to are rejected, so that merging
+	// them can't overflow the span of bins.
+	lo, hi := s.indexes()
+	for _, offset := range []int{math.MinInt64, lo - 1, hi, math.MaxInt64} {
+		bad := newTestSketch(t, params)
+		bad.pos = store{offset: offset, counts: []uint64{1, 1}}
+		bad.count = 2
+
+		_, err := Params{}.Unmarshal(bad.Marshal(nil))
+		assert.Error(t, err)
+	}
+
+	_, err = Params{RelativeAccuracy: MinRelativeAccuracy / 2}.New()
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package ddsketch
+
+// store keeps the counts for a contiguous range of bin indexes. When the
+// range would hold more than the limit of bins, the lowest bins are collapsed
+// together, so that the accuracy of the highest values is kept.
+type store struct {
+	offset int
+	counts []uint64
+}
+
+// add adds count to the bin at the index.
+func (s *store) add(index int, count uint64, limit int) {
+	s.cover(index, index, limit)
+	if index < s.offset {
+		index = s.offset
+	}
+	s.counts[index-s.offset] += count
+}
+
+// merge adds all of the counts from the other store.
+func (s *store) merge(o *store, limit int) {
+	if len(o.counts) == 0 {
+		return
+	}
+	s.cover(o.offset, o.offset+len(o.counts)-1, limit)
+	for i, count := range o.counts {
+		if count > 0 {
+			s.add(o.offset+i, count, limit)
+		}
+	}
+}
+
+// cover grows the range of bins to include lo and hi, collapsing the lowest
+// bins if there would be more than limit of them.
+func (s *store) cover(lo, hi int, limit int) {
+	if len(s.counts) > 0 {
+		if s.offset < lo {
+			lo = s.offset
+		}
+		if top := s.offset + len(s.counts) - 1; top > hi {
+			hi = top
+		}
+	}
+	if hi-lo+1 > limit {
+		lo = hi - limit + 1
+	}
+	if lo == s.offset && hi-lo+1 == len(s.counts) {
+		return
+	}
+
+	counts := make([]uint64, hi-lo+1)
+	for i, count := range s.counts {
+		index := s.offset + i
+		if index < lo {
+			index = lo
+		}
+		counts[index-lo] += count
+	}
+	s.offset, s.counts = lo, counts
+}
@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package exact provides a distribution that keeps every value exactly until
+// there are too many, and then promotes itself to a sketch.
+package exact
@@ -0,0 +1,65 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package exact
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/registry"
+	"github.com/zeebo/errs"
+)
+
+func init() {
+	registry.RegisterDistribution("exact", registry.DistributionMakerFunc(
+		func(ctx context.Context, config interface{}) (dist.Params, error) {
+			params := Params{
+				Load: func(kind string) (dist.Params, error) {
+					return registry.NewDistribution(
+						context.Background(), kind, nil)
+				},
+			}
+
+			if config != nil {
+				a := typeassert.A(config)
+				params.Cap = int(a.I("cap").Int64())
+
+				// every other key is the sketch to promote to, and there
+				// must be at most one.
+				table, _ := a.V().(map[string]interface{})
+				for kind, config := range table {
+					if kind == "cap" {
+						continue
+					}
+					if params.Promote != nil {
+						return nil, errs.New(
+							"only one dist to promote to may be specified")
+					}
+					promote, err := registry.NewDistribution(
+						ctx, kind, config)
+					if err != nil {
+						return nil, err
+					}
+					params.Promote = promote
+				}
+				if err := a.Err(); err != nil {
+					return nil, err
+				}
+			}
+
+			if params.Promote == nil {
+				promote, err := registry.NewDistribution(
+					ctx, DefaultPromote, nil)
+				if err != nil {
+					return nil, err
+				}
+				params.Promote = promote
+			}
+
+			if _, err := params.New(); err != nil {
+				return nil, err
+			}
+			return params, nil
+		}))
+}
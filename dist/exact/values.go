@@ -0,0 +1,370 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package exact
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
+
+// DefaultCap is the cap used if the Params do not specify one.
+const DefaultCap = 50
+
+// DefaultPromote is the kind of distribution promoted to when the config
+// does not specify one.
+const DefaultPromote = "tdigest"
+
+// version is the first byte of every marshaled distribution.
+const version = 1
+
+// Params implements dist.Params for an exact distribution.
+type Params struct {
+	// Cap is the most values kept exactly. Once there are more, the values
+	// are added to a distribution made by Promote instead. If 0, DefaultCap
+	// is used.
+	Cap int
+
+	// Promote makes the distribution used once there are more than Cap
+	// values. It is required to create or unmarshal distributions.
+	Promote dist.Params
+
+	// Load returns the params for a kind of promoted distribution other than
+	// the kind of Promote, so that it can be unmarshaled. If nil, only
+	// promoted distributions of the same kind as Promote can be unmarshaled.
+	Load func(kind string) (dist.Params, error)
+}
+
+// Kind returns the exact distribution kind.
+func (p Params) Kind() string {
+	return "exact"
+}
+
+// New returns a new Values as a dist.Dist.
+func (p Params) New() (dist.Dist, error) {
+	if p.Promote == nil {
+		return nil, errs.New("New called on Params without Promote")
+	}
+	if p.Promote.Kind() == "exact" {
+		return nil, errs.New("can not promote exact to exact")
+	}
+	if p.Cap < 0 {
+		return nil, errs.New("invalid cap: %v", p.Cap)
+	}
+	if p.Cap == 0 {
+		p.Cap = DefaultCap
+	}
+	return &Values{params: p}, nil
+}
+
+// Unmarshal loads a dist.Dist out of some bytes. If the values were promoted,
+// the promoted distribution is loaded with Promote if it is the same kind, and
+// otherwise with the params returned by Load.
+func (p Params) Unmarshal(data []byte) (dist.Dist, error) {
+	if p.Promote == nil {
+		return nil, errs.New("Unmarshal called on Params without Promote")
+	}
+
+	r := reader{buf: data}
+	if r.byte() != version {
+		return nil, errs.New("invalid exact version")
+	}
+	cap := r.uvarint()
+	promoted := r.byte()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if cap == 0 || cap > math.MaxInt32 {
+		return nil, errs.New("invalid cap: %v", cap)
+	}
+	v := &Values{params: Params{
+		Cap:     int(cap),
+		Promote: p.Promote,
+		Load:    p.Load,
+	}}
+
+	switch promoted {
+	case 0:
+		count := r.uvarint()
+		if count > cap || count*8 > uint64(len(r.buf)) {
+			return nil, errs.New("invalid exact value count: %v", count)
+		}
+		v.values = make([]float64, count)
+		for i := range v.values {
+			v.values[i] = r.float64()
+		}
+		if !sort.Float64sAreSorted(v.values) {
+			return nil, errs.New("exact values are not sorted")
+		}
+
+	case 1:
+		size := r.uvarint()
+		if r.err != nil || size > uint64(len(r.buf)) {
+			return nil, errs.New("invalid exact kind")
+		}
+		kind := string(r.buf[:size])
+		params := p.Promote
+		if params.Kind() != kind {
+			if p.Load == nil {
+				return nil, errs.New("unable to load promoted %q", kind)
+			}
+			var err error
+			params, err = p.Load(kind)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sketch, err := params.Unmarshal(r.buf[size:])
+		if err != nil {
+			return nil, err
+		}
+		v.sketch = sketch
+		r.buf = nil
+
+	default:
+		return nil, errs.New("invalid exact promoted flag: %v", promoted)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) > 0 {
+		return nil, errs.New("trailing data after exact")
+	}
+	return v, nil
+}
+
+//
+// Values
+//
+
+// Values implements dist.Dist by keeping every value in sorted order, until
+// there are more than the cap. Then it keeps them in the promoted sketch.
+type Values struct {
+	params Params
+	values []float64
+	sketch dist.Dist
+}
+
+// Kind returns the string "exact".
+func (*Values) Kind() string {
+	return "exact"
+}
+
+// Sketch returns the distribution the values were promoted to, or nil if
+// they are still exact.
+func (v *Values) Sketch() dist.Dist {
+	return v.sketch
+}
+
+// Observe adds the value.
+func (v *Values) Observe(val float64) {
+	v.ObserveWeighted(val, 1)
+}
+
+// ObserveWeighted adds the value weight times. If there would be more values
+// than the cap, the values are promoted first. Values that are NaN, or that
+// would go past the cap when the values can not be promoted, are ignored.
+func (v *Values) ObserveWeighted(val float64, weight int64) {
+	if weight <= 0 || math.IsNaN(val) {
+		return
+	}
+
+	if v.sketch == nil && int64(len(v.values))+weight > int64(v.params.Cap) {
+		if v.promote() != nil {
+			return
+		}
+	}
+	if v.sketch != nil {
+		v.sketch.ObserveWeighted(val, weight)
+		return
+	}
+
+	i, n, w := sort.SearchFloat64s(v.values, val), len(v.values), int(weight)
+	v.values = append(v.values, make([]float64, w)...)
+	copy(v.values[i+w:], v.values[i:n])
+	for j := i; j < i+w; j++ {
+		v.values[j] = val
+	}
+}
+
+// promote moves the values in to a new sketch. If the sketch can not be
+// created, the values are left alone.
+func (v *Values) promote() error {
+	if v.params.Promote == nil {
+		return errs.New("no params to promote exact values with")
+	}
+	sketch, err := v.params.Promote.New()
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	v.Bins(func(value float64, count uint64) bool {
+		sketch.ObserveWeighted(value, int64(count))
+		return true
+	})
+	v.values, v.sketch = nil, sketch
+	return nil
+}
+
+// Merge merges the other Values into these. It returns an error if the values
+// would need to be promoted and can not be.
+func (v *Values) Merge(other dist.Dist) error {
+	o, ok := other.(*Values)
+	if !ok {
+		return errs.New("can not merge %q into exact", other.Kind())
+	}
+
+	// if the other was promoted, these values must be too, so that the
+	// sketches can be merged.
+	if o.sketch != nil {
+		if v.sketch == nil {
+			if err := v.promote(); err != nil {
+				return err
+			}
+		}
+		m, ok := v.sketch.(dist.Merger)
+		if !ok {
+			return errs.New("can not merge %q into exact", o.sketch.Kind())
+		}
+		return m.Merge(o.sketch)
+	}
+
+	if v.sketch == nil && len(v.values)+len(o.values) > v.params.Cap {
+		if err := v.promote(); err != nil {
+			return err
+		}
+	}
+	o.Bins(func(value float64, count uint64) bool {
+		v.ObserveWeighted(value, int64(count))
+		return true
+	})
+	return nil
+}
+
+// Bins calls fn with every distinct value and how many times it was added in
+// increasing order, until it returns false. It does nothing if the values
+// were promoted.
+func (v *Values) Bins(fn func(value float64, count uint64) bool) {
+	for i := 0; i < len(v.values); {
+		j := i + 1
+		for j < len(v.values) && v.values[j] == v.values[i] {
+			j++
+		}
+		if !fn(v.values[i], uint64(j-i)) {
+			return
+		}
+		i = j
+	}
+}
+
+// Query returns the x'th quantile, interpolating between the closest values.
+func (v *Values) Query(x float64) float64 {
+	if v.sketch != nil {
+		return v.sketch.Query(x)
+	}
+	if len(v.values) == 0 {
+		return 0
+	}
+
+	pos := x * float64(len(v.values)-1)
+	if pos <= 0 {
+		return v.values[0]
+	}
+	if pos >= float64(len(v.values)-1) {
+		return v.values[len(v.values)-1]
+	}
+	lo := int(pos)
+	frac := pos - float64(lo)
+	return v.values[lo] + frac*(v.values[lo+1]-v.values[lo])
+}
+
+// CDF returns the fraction of values that are at most x.
+func (v *Values) CDF(x float64) float64 {
+	if v.sketch != nil {
+		return v.sketch.CDF(x)
+	}
+	if len(v.values) == 0 {
+		return 0
+	}
+
+	i := sort.Search(len(v.values), func(i int) bool {
+		return v.values[i] > x
+	})
+	return float64(i) / float64(len(v.values))
+}
+
+// Len returns how many values were added.
+func (v *Values) Len() int64 {
+	if v.sketch != nil {
+		return v.sketch.Len()
+	}
+	return int64(len(v.values))
+}
+
+// Marshal appends a byte form of the values to the provided buffer. If they
+// were promoted, it contains the kind and byte form of the sketch instead.
+func (v *Values) Marshal(buf []byte) []byte {
+	buf = append(buf, version)
+	buf = binary.AppendUvarint(buf, uint64(v.params.Cap))
+
+	if v.sketch != nil {
+		kind := v.sketch.Kind()
+		buf = append(buf, 1)
+		buf = binary.AppendUvarint(buf, uint64(len(kind)))
+		buf = append(buf, kind...)
+		return v.sketch.Marshal(buf)
+	}
+
+	buf = append(buf, 0)
+	buf = binary.AppendUvarint(buf, uint64(len(v.values)))
+	for _, val := range v.values {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(val))
+	}
+	return buf
+}
+
+// reader reads values out of a buffer, remembering the first error.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errs.New("truncated exact")
+	}
+	r.buf = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) float64() float64 {
+	if len(r.buf) < 8 {
+		r.fail()
+		return 0
+	}
+	val := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return val
+}
+
+func (r *reader) uvarint() uint64 {
+	val, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return val
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package exact
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/dist/ddsketch"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+var testParams = Params{
+	Cap:     10,
+	Promote: ddsketch.Params{RelativeAccuracy: 0.01},
+}
+
+func newTestValues(t *testing.T) *Values {
+	d, err := testParams.New()
+	assert.NoError(t, err)
+	return d.(*Values)
+}
+
+func TestValues(t *testing.T) {
+	v := newTestValues(t)
+	for _, val := range []float64{5, 1, 3, 2, 4} {
+		v.Observe(val)
+	}
+	v.ObserveWeighted(3, 2)
+	v.Observe(math.NaN())
+
+	assert.Nil(t, v.Sketch())
+	assert.Equal(t, v.Len(), int64(7))
+	assert.DeepEqual(t, v.values, []float64{1, 2, 3, 3, 3, 4, 5})
+	assert.Equal(t, v.Query(0), 1.0)
+	assert.Equal(t, v.Query(0.5), 3.0)
+	assert.Equal(t, v.Query(0.75), 3.5)
+	assert.Equal(t, v.Query(1), 5.0)
+	assert.Equal(t, v.CDF(0), 0.0)
+	assert.Equal(t, v.CDF(3), 5.0/7)
+	assert.Equal(t, v.CDF(5), 1.0)
+}
+
+func TestValuesPromote(t *testing.T) {
+	v := newTestValues(t)
+	for i := 1; i <= 10; i++ {
+		v.Observe(float64(i))
+	}
+	assert.Nil(t, v.Sketch())
+
+	v.Observe(11)
+	assert.NotNil(t, v.Sketch())
+	assert.Nil(t, v.values)
+	assert.Equal(t, v.Len(), int64(11))
+	assert.Equal(t, v.Query(0), 1.0)
+	assert.Equal(t, v.Query(1), 11.0)
+}
+
+func TestValuesMarshal(t *testing.T) {
+	for _, count := range []int{0, 5, 20} {
+		v := newTestValues(t)
+		for i := 0; i < count; i++ {
+			v.Observe(float64(count - i))
+		}
+
+		data := v.Marshal(nil)
+		got, err := testParams.Unmarshal(data)
+		assert.NoError(t, err)
+		assert.Equal(t, got.Len(), v.Len())
+		assert.Equal(t, got.Query(0.5), v.Query(0.5))
+		assert.DeepEqual(t, got.Marshal(nil), data)
+
+		for i := range data {
+			_, err := testParams.Unmarshal(data[:i])
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestValuesMerge(t *testing.T) {
+	a, b := newTestValues(t), newTestValues(t)
+	a.Observe(1)
+	b.Observe(2)
+	assert.NoError(t, a.Merge(b))
+	assert.Nil(t, a.Sketch())
+	assert.DeepEqual(t, a.values, []float64{1, 2})
+
+	// merging a promoted distribution promotes these values
+	for i := 0; i < 20; i++ {
+		b.Observe(float64(i))
+	}
+	assert.NotNil(t, b.Sketch())
+	assert.NoError(t, a.Merge(b))
+	assert.NotNil(t, a.Sketch())
+	assert.Equal(t, a.Len(), int64(23))
+}
+
+func TestValuesUnpromotable(t *testing.T) {
+	v := &Values{params: Params{
+		Cap:     10,
+		Promote: ddsketch.Params{RelativeAccuracy: 2},
+	}}
+	for i := 0; i < 20; i++ {
+		v.Observe(float64(i))
+	}
+	assert.Nil(t, v.Sketch())
+	assert.Equal(t, v.Len(), int64(10))
+
+	o := newTestValues(t)
+	for i := 0; i < 5; i++ {
+		o.Observe(float64(i))
+	}
+	assert.Error(t, v.Merge(o))
+	assert.Equal(t, v.Len(), int64(10))
+}
+
+func TestValuesUnmarshalLoad(t *testing.T) {
+	v := newTestValues(t)
+	for i := 0; i < 20; i++ {
+		v.Observe(float64(i))
+	}
+	data := v.Marshal(nil)
+
+	_, err := Params{}.Unmarshal(data)
+	assert.Error(t, err)
+
+	params := Params{Promote: tdigest.Params{}}
+	_, err = params.Unmarshal(data)
+	assert.Error(t, err)
+
+	params.Load = func(kind string) (dist.Params, error) {
+		assert.Equal(t, kind, "ddsketch")
+		return testParams.Promote, nil
+	}
+	got, err := params.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, got.Len(), int64(20))
+	assert.Equal(t, got.(*Values).Sketch().Kind(), "ddsketch")
+}
@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package hdr provides an HdrHistogram distribution, which keeps counts of
+// values to a fixed number of significant figures.
+package hdr
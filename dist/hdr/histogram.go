@@ -0,0 +1,428 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package hdr
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
+
+// version is the first byte of every marshaled histogram.
+const version = 1
+
+// unbounded is the largest value, in units of the lowest discernible value,
+// tracked when there is no highest trackable value.
+const unbounded = 1 << 62
+
+// Params implements dist.Params for an HdrHistogram distribution.
+type Params struct {
+	// SignificantFigures is how many significant figures of every value are
+	// kept, and must be in [1, 5].
+	SignificantFigures int
+
+	// LowestDiscernibleValue is the smallest difference between values that
+	// is kept. Values are counted as how many of this unit they are, rounded
+	// down, so that negative values are counted as zero. If 0, 1 is used.
+	LowestDiscernibleValue float64
+
+	// HighestTrackableValue is the largest value that is counted as is. Any
+	// larger values are counted as it. If 0, there is no practical limit.
+	HighestTrackableValue float64
+}
+
+// Kind returns the HdrHistogram distribution kind.
+func (p Params) Kind() string {
+	return "hdr"
+}
+
+// New returns a new Histogram as a dist.Dist.
+func (p Params) New() (dist.Dist, error) {
+	if p.SignificantFigures == 0 {
+		return nil, errs.New("New called on zero value Params")
+	}
+	if p.LowestDiscernibleValue == 0 {
+		p.LowestDiscernibleValue = 1
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return newHistogram(p), nil
+}
+
+// validate checks that the params are in range.
+func (p Params) validate() error {
+	if p.SignificantFigures < 1 || p.SignificantFigures > 5 {
+		return errs.New("invalid significant figures: %v",
+			p.SignificantFigures)
+	}
+	if !(p.LowestDiscernibleValue > 0) ||
+		math.IsInf(p.LowestDiscernibleValue, 0) {
+		return errs.New("invalid lowest discernible value: %v",
+			p.LowestDiscernibleValue)
+	}
+	if p.HighestTrackableValue != 0 &&
+		!(p.HighestTrackableValue >= 2*p.LowestDiscernibleValue) {
+		return errs.New("invalid highest trackable value: %v",
+			p.HighestTrackableValue)
+	}
+	return nil
+}
+
+// Unmarshal loads a dist.Dist out of some bytes.
+func (p Params) Unmarshal(data []byte) (dist.Dist, error) {
+	return unmarshalHistogram(data)
+}
+
+//
+// Histogram
+//
+
+// Histogram implements dist.Dist for an HdrHistogram. Values are counted in
+// buckets that double in size, each split in to enough sub-buckets to keep
+// the significant figures. Only the sub-buckets with values are stored.
+type Histogram struct {
+	params   Params
+	halfMag  uint
+	halfSize int
+	highest  uint64
+
+	bins   map[int]uint64
+	sorted []int
+	count  uint64
+	min    float64
+	max    float64
+}
+
+// newHistogram constructs a Histogram with the validated params.
+func newHistogram(p Params) *Histogram {
+	// enough sub-buckets to tell apart every value up to 2 * 10^figures.
+	single := 2 * math.Pow(10, float64(p.SignificantFigures))
+	mag := uint(math.Ceil(math.Log2(single)))
+
+	highest := uint64(unbounded)
+	if p.HighestTrackableValue != 0 {
+		units := p.HighestTrackableValue / p.LowestDiscernibleValue
+		if units < unbounded {
+			highest = uint64(units)
+		}
+	}
+
+	return &Histogram{
+		params:   p,
+		halfMag:  mag - 1,
+		halfSize: 1 << (mag - 1),
+		highest:  highest,
+		bins:     make(map[int]uint64),
+	}
+}
+
+// Kind returns the string "hdr".
+func (*Histogram) Kind() string {
+	return "hdr"
+}
+
+// Observe adds the value to the histogram.
+func (h *Histogram) Observe(val float64) {
+	h.ObserveWeighted(val, 1)
+}
+
+// ObserveWeighted adds the value to the histogram weight times. Values that
+// are NaN or infinite are ignored.
+func (h *Histogram) ObserveWeighted(val float64, weight int64) {
+	if weight <= 0 || math.IsNaN(val) || math.IsInf(val, 0) {
+		return
+	}
+
+	units := uint64(0)
+	if scaled := val / h.params.LowestDiscernibleValue; scaled >= 1 {
+		units = h.highest
+		if scaled < float64(h.highest) {
+			units = uint64(scaled)
+		}
+	}
+	h.add(h.index(units), uint64(weight))
+
+	if h.count == 0 || val < h.min {
+		h.min = val
+	}
+	if h.count == 0 || val > h.max {
+		h.max = val
+	}
+	h.count += uint64(weight)
+}
+
+// add adds the count to the sub-bucket with the index.
+func (h *Histogram) add(index int, count uint64) {
+	if _, ok := h.bins[index]; !ok {
+		h.sorted = nil
+	}
+	h.bins[index] += count
+}
+
+// Merge merges the other histogram into this one. The other histogram must
+// be a Histogram with the same params.
+func (h *Histogram) Merge(other dist.Dist) error {
+	o, ok := other.(*Histogram)
+	if !ok {
+		return errs.New("can not merge %q into hdr", other.Kind())
+	}
+	if o.params != h.params {
+		return errs.New("can not merge hdr with params %+v into one with %+v",
+			o.params, h.params)
+	}
+	if o.count == 0 {
+		return nil
+	}
+
+	for index, count := range o.bins {
+		h.add(index, count)
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if h.count == 0 || o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	return nil
+}
+
+// index returns the index of the sub-bucket for the value in units.
+func (h *Histogram) index(units uint64) int {
+	mask := uint64(2*h.halfSize - 1)
+	bucket := 64 - bits.LeadingZeros64(units|mask) - int(h.halfMag+1)
+	sub := int(units >> uint(bucket))
+	return (bucket+1)<<h.halfMag + sub - h.halfSize
+}
+
+// value returns the value in the middle of the sub-bucket with the index.
+func (h *Histogram) value(index int) float64 {
+	bucket := index>>h.halfMag - 1
+	sub := index&(h.halfSize-1) + h.halfSize
+	if bucket < 0 {
+		sub -= h.halfSize
+		bucket = 0
+	}
+	lowest := uint64(sub) << uint(bucket)
+	width := uint64(1) << uint(bucket)
+	return float64(lowest+width/2) * h.params.LowestDiscernibleValue
+}
+
+// indexes returns the indexes of the sub-buckets with values in order.
+func (h *Histogram) indexes() []int {
+	if h.sorted == nil {
+		h.sorted = make([]int, 0, len(h.bins))
+		for index := range h.bins {
+			h.sorted = append(h.sorted, index)
+		}
+		sort.Ints(h.sorted)
+	}
+	return h.sorted
+}
+
+// Bins calls fn with the value in the middle and count of every non-empty
+// sub-bucket in increasing order of value, until it returns false.
+func (h *Histogram) Bins(fn func(value float64, count uint64) bool) {
+	for _, index := range h.indexes() {
+		if !fn(h.value(index), h.bins[index]) {
+			return
+		}
+	}
+}
+
+// Query returns the approximate x'th quantile.
+func (h *Histogram) Query(x float64) (out float64) {
+	if h.count == 0 {
+		return 0
+	}
+	if x <= 0 {
+		return h.min
+	}
+	if x >= 1 {
+		return h.max
+	}
+
+	rank := x * float64(h.count-1)
+	out = h.max
+	var sum uint64
+	h.Bins(func(value float64, count uint64) bool {
+		sum += count
+		if float64(sum) > rank {
+			out = value
+			return false
+		}
+		return true
+	})
+
+	return math.Max(h.min, math.Min(h.max, out))
+}
+
+// CDF returns the approximate CDF at the value x. Values in the same
+// sub-bucket as x are counted as being half below it.
+func (h *Histogram) CDF(x float64) float64 {
+	if h.count == 0 || x < h.min {
+		return 0
+	}
+	if x >= h.max {
+		return 1
+	}
+
+	units := uint64(0)
+	if scaled := x / h.params.LowestDiscernibleValue; scaled >= 1 {
+		units = h.highest
+		if scaled < float64(h.highest) {
+			units = uint64(scaled)
+		}
+	}
+	target := h.index(units)
+
+	var below float64
+	for _, index := range h.indexes() {
+		if index > target {
+			break
+		} else if index == target {
+			below += float64(h.bins[index]) / 2
+		} else {
+			below += float64(h.bins[index])
+		}
+	}
+
+	return below / float64(h.count)
+}
+
+// Len returns how many items were added to the histogram.
+func (h *Histogram) Len() int64 {
+	return int64(h.count)
+}
+
+// Marshal appends a byte form of the histogram to the provided buffer. Only
+// the sub-buckets with values are included, each as the difference from the
+// previous index and the count.
+func (h *Histogram) Marshal(buf []byte) []byte {
+	buf = append(buf, version)
+	buf = binary.AppendUvarint(buf, uint64(h.params.SignificantFigures))
+	buf = appendFloat64(buf, h.params.LowestDiscernibleValue)
+	buf = appendFloat64(buf, h.params.HighestTrackableValue)
+	buf = appendFloat64(buf, h.min)
+	buf = appendFloat64(buf, h.max)
+
+	indexes := h.indexes()
+	buf = binary.AppendUvarint(buf, uint64(len(indexes)))
+	prev := 0
+	for _, index := range indexes {
+		buf = binary.AppendUvarint(buf, uint64(index-prev))
+		buf = binary.AppendUvarint(buf, h.bins[index])
+		prev = index
+	}
+	return buf
+}
+
+// appendFloat64 appends the bits of the float in little endian order.
+func appendFloat64(buf []byte, val float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(val))
+}
+
+// unmarshalHistogram parses a histogram in the form that Marshal writes.
+func unmarshalHistogram(data []byte) (*Histogram, error) {
+	r := reader{buf: data}
+	if r.byte() != version {
+		return nil, errs.New("invalid hdr version")
+	}
+
+	figures := r.uvarint()
+	params := Params{
+		SignificantFigures:     int(figures),
+		LowestDiscernibleValue: r.float64(),
+		HighestTrackableValue:  r.float64(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if figures > 5 {
+		return nil, errs.New("invalid significant figures: %v", figures)
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	h := newHistogram(params)
+	h.min = r.float64()
+	h.max = r.float64()
+
+	// the largest index is for the largest value that can be tracked.
+	last := h.index(math.MaxUint64)
+
+	bins := r.uvarint()
+	if bins > uint64(len(r.buf)) {
+		return nil, errs.New("invalid hdr bin count: %v", bins)
+	}
+	h.sorted = make([]int, 0, bins)
+	index := 0
+	for i := uint64(0); i < bins && r.err == nil; i++ {
+		delta, count := r.uvarint(), r.uvarint()
+		if (i > 0 && delta == 0) || delta > uint64(last-index) {
+			return nil, errs.New("invalid hdr bin index")
+		}
+		index += int(delta)
+		h.bins[index] = count
+		h.sorted = append(h.sorted, index)
+		h.count += count
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) > 0 {
+		return nil, errs.New("trailing data after hdr")
+	}
+	return h, nil
+}
+
+// reader reads values out of a buffer, remembering the first error.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errs.New("truncated hdr")
+	}
+	r.buf = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) float64() float64 {
+	if len(r.buf) < 8 {
+		r.fail()
+		return 0
+	}
+	val := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return val
+}
+
+func (r *reader) uvarint() uint64 {
+	val, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return val
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package hdr
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func newTestHistogram(t *testing.T, params Params) *Histogram {
+	d, err := params.New()
+	assert.NoError(t, err)
+	return d.(*Histogram)
+}
+
+func TestHistogram(t *testing.T) {
+	h := newTestHistogram(t, Params{SignificantFigures: 3})
+
+	rng := rand.New(rand.NewSource(1))
+	var vals []float64
+	for i := 0; i < 10000; i++ {
+		val := math.Floor(rng.ExpFloat64() * 1e5)
+		vals = append(vals, val)
+		h.Observe(val)
+	}
+	sort.Float64s(vals)
+
+	assert.Equal(t, h.Len(), int64(len(vals)))
+	assert.Equal(t, h.Query(0), vals[0])
+	assert.Equal(t, h.Query(1), vals[len(vals)-1])
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		want := vals[int(q*float64(len(vals)-1))]
+		if got := h.Query(q); math.Abs(got-want) > 1e-3*want+1 {
+			t.Fatalf("query(%v) = %v, want %v", q, got, want)
+		}
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		val := vals[int(q*float64(len(vals)))]
+		if cdf := h.CDF(val); math.Abs(cdf-q) > 0.01 {
+			t.Fatalf("cdf(%v) = %v, want %v", val, cdf, q)
+		}
+	}
+	assert.Equal(t, h.CDF(vals[0]-1), 0.0)
+	assert.Equal(t, h.CDF(vals[len(vals)-1]), 1.0)
+}
+
+func TestHistogramUnits(t *testing.T) {
+	h := newTestHistogram(t, Params{
+		SignificantFigures:     2,
+		LowestDiscernibleValue: 0.5,
+		HighestTrackableValue:  100,
+	})
+
+	h.Observe(-3)
+	h.Observe(0.25)
+	h.Observe(10.25)
+	h.ObserveWeighted(1000, 2)
+
+	assert.Equal(t, h.Len(), int64(5))
+	assert.Equal(t, h.Query(0), -3.0)
+	assert.Equal(t, h.Query(0.25), 0.0)
+	assert.Equal(t, h.Query(0.5), 10.0)
+	assert.Equal(t, h.Query(0.75), 100.0)
+	assert.Equal(t, h.Query(1), 1000.0)
+}
+
+func TestHistogramMarshal(t *testing.T) {
+	h := newTestHistogram(t, Params{
+		SignificantFigures:    3,
+		HighestTrackableValue: 1e6,
+	})
+	for i := 0; i < 1000; i++ {
+		h.ObserveWeighted(float64(i*i), int64(i%3+1))
+	}
+	h.Observe(math.NaN())
+	h.ObserveWeighted(1, 0)
+
+	data := h.Marshal(nil)
+	got, err := Params{}.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, got, h)
+	assert.DeepEqual(t, got.Marshal(nil), data)
+
+	for i := range data {
+		_, err := Params{}.Unmarshal(data[:i])
+		assert.Error(t, err)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	params := Params{SignificantFigures: 3}
+	a, b, all := newTestHistogram(t, params), newTestHistogram(t, params),
+		newTestHistogram(t, params)
+
+	for i := 0; i < 1000; i++ {
+		a.Observe(float64(i))
+		b.Observe(float64(i) * 1000)
+		all.Observe(float64(i))
+		all.Observe(float64(i) * 1000)
+	}
+
+	assert.NoError(t, a.Merge(b))
+	assert.Equal(t, a.Len(), all.Len())
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		assert.Equal(t, a.Query(q), all.Query(q))
+	}
+
+	other := newTestHistogram(t, Params{SignificantFigures: 2})
+	assert.Error(t, a.Merge(other))
+}
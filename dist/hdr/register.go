@@ -0,0 +1,41 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package hdr
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/registry"
+)
+
+func init() {
+	registry.RegisterDistribution("hdr", registry.DistributionMakerFunc(
+		func(ctx context.Context, config interface{}) (dist.Params, error) {
+			if config == nil {
+				return Params{}, nil
+			}
+
+			a := typeassert.A(config)
+			params := Params{
+				SignificantFigures:     int(a.I("significant_figures").Int64()),
+				LowestDiscernibleValue: number(a.I("lowest_discernible_value")),
+				HighestTrackableValue:  number(a.I("highest_trackable_value")),
+			}
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			return params, nil
+		}))
+}
+
+// number asserts the value as a float64, allowing it to be written as an
+// integer in the config.
+func number(a *typeassert.Asserter) float64 {
+	if val, ok := a.V().(int64); ok {
+		return float64(val)
+	}
+	return a.Float64()
+}
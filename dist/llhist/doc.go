@@ -0,0 +1,16 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package llhist provides a log-linear histogram distribution, in the style
+// of circllhist. Every value is counted in a fixed bucket that keeps two
+// significant decimal digits, so histograms are deterministic and can be
+// merged without any loss, no matter what host they came from.
+//
+// A marshaled histogram is 17 bytes, plus 1 to 3 bytes for the number of
+// buckets holding values, plus for each of them 3 bytes if it holds fewer than
+// 128 values, 4 bytes if fewer than 16384, and 5 bytes if fewer than 2097152.
+// There are 90 buckets for every power of ten, so values spanning d powers of
+// ten need at most 90*d buckets. For example, latencies from 1ms to 10s with
+// fewer than 16384 values in any bucket use at most 360 buckets, and so at
+// most 1459 bytes. The size of a files database record should be chosen with
+// that in mind, or the records will be split.
+package llhist
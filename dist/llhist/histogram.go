@@ -0,0 +1,333 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package llhist
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/zeebo/errs"
+)
+
+// version is the first byte of every marshaled histogram.
+const version = 1
+
+// Params implements dist.Params for a log-linear histogram. The buckets are
+// fixed, so there is nothing to configure.
+type Params struct{}
+
+// Kind returns the log-linear histogram distribution kind.
+func (p Params) Kind() string {
+	return "llhist"
+}
+
+// New returns a new Histogram as a dist.Dist.
+func (p Params) New() (dist.Dist, error) {
+	return newHistogram(), nil
+}
+
+// Unmarshal loads a dist.Dist out of some bytes.
+func (p Params) Unmarshal(data []byte) (dist.Dist, error) {
+	return unmarshalHistogram(data)
+}
+
+//
+// buckets
+//
+
+// bucket identifies the values val * 10^(exp-1) up to, but not including,
+// (val+1) * 10^(exp-1), where val has two digits and its sign is the sign of
+// the values. The zero bucket has a val of 0 and holds values too small for
+// any other bucket.
+type bucket struct {
+	val int8
+	exp int8
+}
+
+// bucketOf returns the bucket for the value. Values too large for any bucket
+// are put in the largest one.
+func bucketOf(x float64) bucket {
+	abs := math.Abs(x)
+	if !(abs >= 1e-128) {
+		return bucket{}
+	}
+	if abs >= 1e128 {
+		if x < 0 {
+			return bucket{val: -99, exp: 127}
+		}
+		return bucket{val: 99, exp: 127}
+	}
+
+	// the estimates from log10 may be off by one near powers of ten, so
+	// correct them.
+	exp := int(math.Floor(math.Log10(abs)))
+	val := int(abs / math.Pow10(exp-1))
+	if val >= 100 {
+		exp++
+	} else if val < 10 {
+		exp--
+	}
+	if exp > 127 {
+		exp = 127
+	} else if exp < -128 {
+		exp = -128
+	}
+	val = int(abs / math.Pow10(exp-1))
+	if val > 99 {
+		val = 99
+	} else if val < 10 {
+		val = 10
+	}
+
+	if x < 0 {
+		val = -val
+	}
+	return bucket{val: int8(val), exp: int8(exp)}
+}
+
+// bounds returns the smallest value in the bucket, and how wide it is.
+func (b bucket) bounds() (lower, width float64) {
+	if b.val == 0 {
+		return 0, 0
+	}
+	width = math.Pow10(int(b.exp) - 1)
+	if b.val < 0 {
+		return float64(b.val-1) * width, width
+	}
+	return float64(b.val) * width, width
+}
+
+//
+// Histogram
+//
+
+// Histogram implements dist.Dist for a log-linear histogram.
+type Histogram struct {
+	bins   map[bucket]uint64
+	sorted []bucket
+	count  uint64
+	min    float64
+	max    float64
+}
+
+// newHistogram constructs an empty Histogram.
+func newHistogram() *Histogram {
+	return &Histogram{
+		bins: make(map[bucket]uint64),
+	}
+}
+
+// Kind returns the string "llhist".
+func (*Histogram) Kind() string {
+	return "llhist"
+}
+
+// Observe adds the value to the histogram.
+func (h *Histogram) Observe(val float64) {
+	h.ObserveWeighted(val, 1)
+}
+
+// ObserveWeighted adds the value to the histogram weight times. Values that
+// are NaN or infinite are ignored.
+func (h *Histogram) ObserveWeighted(val float64, weight int64) {
+	if weight <= 0 || math.IsNaN(val) || math.IsInf(val, 0) {
+		return
+	}
+
+	h.add(bucketOf(val), uint64(weight))
+
+	if h.count == 0 || val < h.min {
+		h.min = val
+	}
+	if h.count == 0 || val > h.max {
+		h.max = val
+	}
+	h.count += uint64(weight)
+}
+
+// add adds the count to the bucket.
+func (h *Histogram) add(b bucket, count uint64) {
+	if _, ok := h.bins[b]; !ok {
+		h.sorted = nil
+	}
+	h.bins[b] += count
+}
+
+// Merge merges the other histogram into this one.
+func (h *Histogram) Merge(other dist.Dist) error {
+	o, ok := other.(*Histogram)
+	if !ok {
+		return errs.New("can not merge %q into llhist", other.Kind())
+	}
+	if o.count == 0 {
+		return nil
+	}
+
+	for b, count := range o.bins {
+		h.add(b, count)
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if h.count == 0 || o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	return nil
+}
+
+// buckets returns the buckets with values in increasing order.
+func (h *Histogram) buckets() []bucket {
+	if h.sorted == nil {
+		h.sorted = make([]bucket, 0, len(h.bins))
+		for b := range h.bins {
+			h.sorted = append(h.sorted, b)
+		}
+		sort.Slice(h.sorted, func(i, j int) bool {
+			li, _ := h.sorted[i].bounds()
+			lj, _ := h.sorted[j].bounds()
+			return li < lj
+		})
+	}
+	return h.sorted
+}
+
+// Bins calls fn with the value in the middle and count of every non-empty
+// bucket in increasing order of value, until it returns false.
+func (h *Histogram) Bins(fn func(value float64, count uint64) bool) {
+	for _, b := range h.buckets() {
+		lower, width := b.bounds()
+		if !fn(lower+width/2, h.bins[b]) {
+			return
+		}
+	}
+}
+
+// Query returns the approximate x'th quantile, assuming the values in a bucket
+// are spread evenly across it.
+func (h *Histogram) Query(x float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	if x <= 0 {
+		return h.min
+	}
+	if x >= 1 {
+		return h.max
+	}
+
+	rank := x * float64(h.count)
+	out := h.max
+	var before float64
+	for _, b := range h.buckets() {
+		count := float64(h.bins[b])
+		if before+count > rank {
+			lower, width := b.bounds()
+			out = lower + width*(rank-before)/count
+			break
+		}
+		before += count
+	}
+
+	return math.Max(h.min, math.Min(h.max, out))
+}
+
+// CDF returns the approximate CDF at the value x, assuming the values in a
+// bucket are spread evenly across it.
+func (h *Histogram) CDF(x float64) float64 {
+	if h.count == 0 || x < h.min {
+		return 0
+	}
+	if x >= h.max {
+		return 1
+	}
+
+	var below float64
+	for _, b := range h.buckets() {
+		lower, width := b.bounds()
+		if x < lower {
+			break
+		}
+		count := float64(h.bins[b])
+		if x < lower+width {
+			below += count * (x - lower) / width
+			break
+		}
+		below += count
+	}
+
+	return below / float64(h.count)
+}
+
+// Len returns how many items were added to the histogram.
+func (h *Histogram) Len() int64 {
+	return int64(h.count)
+}
+
+// Marshal appends a byte form of the histogram to the provided buffer. Only
+// the buckets with values are included, in increasing order.
+func (h *Histogram) Marshal(buf []byte) []byte {
+	buf = append(buf, version)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(h.min))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(h.max))
+
+	buckets := h.buckets()
+	buf = binary.AppendUvarint(buf, uint64(len(buckets)))
+	for _, b := range buckets {
+		buf = append(buf, byte(b.val), byte(b.exp))
+		buf = binary.AppendUvarint(buf, h.bins[b])
+	}
+	return buf
+}
+
+// unmarshalHistogram parses a histogram in the form that Marshal writes.
+func unmarshalHistogram(data []byte) (*Histogram, error) {
+	if len(data) < 17 || data[0] != version {
+		return nil, errs.New("invalid llhist header")
+	}
+	h := newHistogram()
+	h.min = math.Float64frombits(binary.LittleEndian.Uint64(data[1:]))
+	h.max = math.Float64frombits(binary.LittleEndian.Uint64(data[9:]))
+	data = data[17:]
+
+	bins, n := binary.Uvarint(data)
+	if n <= 0 || bins > uint64(len(data)) {
+		return nil, errs.New("invalid llhist bucket count")
+	}
+	data = data[n:]
+
+	h.sorted = make([]bucket, 0, bins)
+	for i := uint64(0); i < bins; i++ {
+		if len(data) < 2 {
+			return nil, errs.New("truncated llhist")
+		}
+		b := bucket{val: int8(data[0]), exp: int8(data[1])}
+		if b.val != 0 && (b.val < -99 || (b.val > -10 && b.val < 10)) {
+			return nil, errs.New("invalid llhist bucket: %v", b)
+		}
+		if len(h.sorted) > 0 {
+			prev, _ := h.sorted[len(h.sorted)-1].bounds()
+			if lower, _ := b.bounds(); !(lower > prev) {
+				return nil, errs.New("unordered llhist bucket: %v", b)
+			}
+		}
+
+		count, n := binary.Uvarint(data[2:])
+		if n <= 0 {
+			return nil, errs.New("truncated llhist")
+		}
+		data = data[2+n:]
+
+		h.bins[b] = count
+		h.sorted = append(h.sorted, b)
+		h.count += count
+	}
+
+	if len(data) > 0 {
+		return nil, errs.New("trailing data after llhist")
+	}
+	return h, nil
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package llhist
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func newTestHistogram(t *testing.T) *Histogram {
+	d, err := Params{}.New()
+	assert.NoError(t, err)
+	return d.(*Histogram)
+}
+
+func TestBucketOf(t *testing.T) {
+	for _, test := range []struct {
+		in  float64
+		out bucket
+	}{
+		{0, bucket{}},
+		{1e-200, bucket{}},
+		{1, bucket{10, 0}},
+		{1.25, bucket{12, 0}},
+		{99.9, bucket{99, 1}},
+		{100, bucket{10, 2}},
+		{1000, bucket{10, 3}},
+		{0.001, bucket{10, -3}},
+		{-4.56, bucket{-45, 0}},
+		{1e200, bucket{99, 127}},
+	} {
+		assert.Equal(t, bucketOf(test.in), test.out)
+	}
+
+	// every value is in the bounds of its bucket
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		val := math.Exp(rng.NormFloat64()*50) * float64(rng.Intn(3)-1)
+		lower, width := bucketOf(val).bounds()
+		if val != 0 && (val < lower || val >= lower+width*(1+1e-9)) {
+			t.Fatalf("%v not in [%v, %v)", val, lower, lower+width)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newTestHistogram(t)
+
+	rng := rand.New(rand.NewSource(1))
+	var vals []float64
+	for i := 0; i < 10000; i++ {
+		val := math.Exp(rng.NormFloat64() * 3)
+		vals = append(vals, val)
+		h.Observe(val)
+	}
+	sort.Float64s(vals)
+
+	assert.Equal(t, h.Len(), int64(len(vals)))
+	assert.Equal(t, h.Query(0), vals[0])
+	assert.Equal(t, h.Query(1), vals[len(vals)-1])
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+		want := vals[int(q*float64(len(vals)))]
+		if got := h.Query(q); math.Abs(got-want) > 0.1*want {
+			t.Fatalf("query(%v) = %v, want %v", q, got, want)
+		}
+		if cdf := h.CDF(want); math.Abs(cdf-q) > 0.01 {
+			t.Fatalf("cdf(%v) = %v, want %v", want, cdf, q)
+		}
+	}
+	assert.Equal(t, h.CDF(vals[0]/2), 0.0)
+	assert.Equal(t, h.CDF(vals[len(vals)-1]), 1.0)
+}
+
+func TestHistogramMarshal(t *testing.T) {
+	h := newTestHistogram(t)
+	for i := -100; i < 1000; i++ {
+		h.ObserveWeighted(float64(i)*1.5, int64(i%3+1))
+	}
+	h.Observe(math.NaN())
+	h.ObserveWeighted(1, 0)
+
+	data := h.Marshal(nil)
+	got, err := Params{}.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, got, h)
+	assert.DeepEqual(t, got.Marshal(nil), data)
+
+	for i := range data {
+		_, err := Params{}.Unmarshal(data[:i])
+		assert.Error(t, err)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b, all := newTestHistogram(t), newTestHistogram(t),
+		newTestHistogram(t)
+
+	for i := 0; i < 1000; i++ {
+		a.Observe(float64(i))
+		b.Observe(-float64(i) * 1000)
+		all.Observe(float64(i))
+		all.Observe(-float64(i) * 1000)
+	}
+
+	// merging is lossless, so it is the same as adding everything to one.
+	assert.NoError(t, a.Merge(b))
+	assert.DeepEqual(t, a.Marshal(nil), all.Marshal(nil))
+}
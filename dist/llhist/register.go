@@ -0,0 +1,17 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package llhist
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/registry"
+)
+
+func init() {
+	registry.RegisterDistribution("llhist", registry.DistributionMakerFunc(
+		func(ctx context.Context, config interface{}) (dist.Params, error) {
+			return Params{}, nil
+		}))
+}
@@ -3,6 +3,8 @@
 package tdigest
 
 import (
+	"math"
+
 	"github.com/vivint/rothko/dist"
 	"github.com/zeebo/errs"
 	"github.com/zeebo/tdigest"
@@ -65,6 +67,30 @@ func (w Wrapper) Observe(val float64) {
 	w.td.Add(val)
 }
 
+// ObserveWeighted adds the value to the t-digest weight times.
+func (w Wrapper) ObserveWeighted(val float64, weight int64) {
+	for weight > 0 {
+		count := uint32(math.MaxUint32)
+		if weight < math.MaxUint32 {
+			count = uint32(weight)
+		}
+		w.td.AddWeighted(val, count)
+		weight -= int64(count)
+	}
+}
+
+// Merge merges the other t-digest into this one.
+func (w Wrapper) Merge(other dist.Dist) error {
+	var td *tdigest.TDigest
+	switch other := other.(type) {
+	case *Wrapper:
+		td = other.td
+	default:
+		return errs.New("can not merge %q into tdigest", other.Kind())
+	}
+	return errs.Wrap(w.td.Merge(td))
+}
+
 // Marshal appends a byte form of the t-digest to the provided buffer.
 func (w Wrapper) Marshal(buf []byte) []byte {
 	return w.td.Marshal(buf)
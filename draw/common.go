@@ -15,11 +15,19 @@ type Canvas interface {
 
 // Column represents a column to draw in a context. Data is expected to be
 // sorted, non-empty, and contain typical floats (no NaNs/denormals/Inf/etc).
-// Obs is the number of observations.
+// Obs is the number of observations. MinId and MaxId are the ids of the
+// observations that produced the minimum and maximum values, if known. Mean
+// and Stddev are computed from the exact sums of the values if Exact is
+// true, and estimated from the Data otherwise.
 type Column struct {
 	X, W   int
 	Data   []float64
 	ObsSec float64
+
+	MinId, MaxId string
+
+	Mean, Stddev float64
+	Exact        bool
 }
 
 // Color is a simple 8 bits per channel color.
@@ -67,7 +67,8 @@ func (d *Dumper) Run(ctx context.Context, w *data.Writer) (err error) {
 }
 
 // Dump writes all of the metrics Captured from the Writer into the DB
-// associated with the Dumper.
+// associated with the Dumper. Only the records for the flush duration are
+// written, and never the Writer's rolling windows.
 func (d *Dumper) Dump(ctx context.Context, w *data.Writer) {
 	var wg sync.WaitGroup
 	writes := int64(0)
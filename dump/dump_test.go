@@ -0,0 +1,88 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package dump
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/database"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestDumpFlushWindow(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWindowedWriter(tdigest.Params{Compression: 5}, time.Hour)
+	db := newFakeDB()
+	d := New(Options{DB: db})
+
+	w.Add(ctx, "foo", 1, nil)
+	w.Add(ctx, "foo", 2, nil)
+	w.Add(ctx, "bar", 3, nil)
+	d.Dump(ctx, w)
+
+	w.Add(ctx, "foo", 4, nil)
+	d.Dump(ctx, w)
+
+	// every dump only persists what was added since the last one.
+	assert.DeepEqual(t, db.observations(t), map[string][]int64{
+		"foo": {2, 1},
+		"bar": {1},
+	})
+
+	// the rolling window still has everything.
+	got := make(map[string]int64)
+	assert.NoError(t, w.Iterate(ctx, time.Hour,
+		func(ctx context.Context, metric string, rec data.Record) bool {
+			got[metric] = rec.Observations
+			return true
+		}))
+	assert.DeepEqual(t, got, map[string]int64{"foo": 3, "bar": 1})
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeDB struct {
+	database.DB
+
+	mu      sync.Mutex
+	records map[string][][]byte
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{records: make(map[string][][]byte)}
+}
+
+func (f *fakeDB) Queue(ctx context.Context, metric string, start, end int64,
+	data []byte, cb func(written bool, err error)) error {
+
+	f.mu.Lock()
+	f.records[metric] = append(f.records[metric],
+		append([]byte(nil), data...))
+	f.mu.Unlock()
+
+	cb(true, nil)
+	return nil
+}
+
+func (f *fakeDB) observations(t *testing.T) map[string][]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make(map[string][]int64)
+	for metric, records := range f.records {
+		for _, buf := range records {
+			var rec data.Record
+			assert.NoError(t, rec.Unmarshal(buf))
+			out[metric] = append(out[metric], rec.Observations)
+		}
+	}
+	return out
+}
@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package cumulative turns cumulative counters into changes since the last
+// time they were seen.
+package cumulative
@@ -0,0 +1,140 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package cumulative
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a series is remembered after it was last seen if no
+// other ttl is given.
+const DefaultTTL = 10 * time.Minute
+
+// Tracker keeps track of the last values of cumulative series. Series that
+// have not been seen for the ttl are forgotten, so that series that go away
+// do not use memory forever. It is safe for concurrent use.
+type Tracker struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	swept   time.Time
+	buckets map[string]counts
+	rates   map[string]sample
+}
+
+// counts are the last counts of the keys of a series and when they were
+// seen.
+type counts struct {
+	values map[string]float64
+	seen   time.Time
+}
+
+// sample is the last value of a series, the time of the value, and when it
+// was seen.
+type sample struct {
+	value float64
+	at    time.Time
+	seen  time.Time
+}
+
+// NewTracker constructs an empty Tracker that forgets series after they have
+// not been seen for the ttl. If the ttl is zero, DefaultTTL is used.
+func NewTracker(ttl time.Duration) *Tracker {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Tracker{
+		ttl:     ttl,
+		now:     time.Now,
+		buckets: make(map[string]counts),
+		rates:   make(map[string]sample),
+	}
+}
+
+// sweep removes any series that have not been seen for the ttl. It only
+// looks at every series once per ttl. It must be called with the mutex held.
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.ttl {
+		return
+	}
+	t.swept = now
+
+	for series, c := range t.buckets {
+		if now.Sub(c.seen) >= t.ttl {
+			delete(t.buckets, series)
+		}
+	}
+	for series, s := range t.rates {
+		if now.Sub(s.seen) >= t.ttl {
+			delete(t.rates, series)
+		}
+	}
+}
+
+// Deltas stores the cumulative counts for the keys of the series and
+// replaces them with the change since the last call. It returns false if the
+// series has not been seen before, in which case the counts are only used as
+// a baseline. If any count went down, the series was reset and the counts are
+// left as they are.
+func (t *Tracker) Deltas(series string, keys []string, values []float64) bool {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(now)
+
+	last, ok := t.buckets[series]
+	next := counts{
+		values: make(map[string]float64, len(keys)),
+		seen:   now,
+	}
+	t.buckets[series] = next
+
+	reset := false
+	for i, key := range keys {
+		next.values[key] = values[i]
+		if values[i] < last.values[key] {
+			reset = true
+		}
+	}
+
+	if !ok {
+		return false
+	}
+	if !reset {
+		for i, key := range keys {
+			values[i] -= last.values[key]
+		}
+	}
+	return true
+}
+
+// Rate stores the cumulative value of the series and returns the per second
+// rate of change since the last call. It returns false if the series has not
+// been seen before or no time has passed. If the value went down, the series
+// was reset and the whole value is used.
+func (t *Tracker) Rate(series string, value float64, at time.Time) (
+	float64, bool) {
+
+	now := t.now()
+
+	t.mu.Lock()
+	t.sweep(now)
+	last, ok := t.rates[series]
+	t.rates[series] = sample{value: value, at: at, seen: now}
+	t.mu.Unlock()
+
+	elapsed := at.Sub(last.at).Seconds()
+	if !ok || elapsed <= 0 {
+		return 0, false
+	}
+
+	delta := value - last.value
+	if delta < 0 {
+		delta = value
+	}
+	return delta / elapsed, true
+}
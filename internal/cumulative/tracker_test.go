@@ -0,0 +1,75 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package cumulative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestTrackerDeltas(t *testing.T) {
+	tr := NewTracker(0)
+	keys := []string{"a", "b"}
+
+	counts := []float64{1, 2}
+	assert.That(t, !tr.Deltas("s", keys, counts))
+	assert.DeepEqual(t, counts, []float64{1, 2})
+
+	counts = []float64{3, 2}
+	assert.That(t, tr.Deltas("s", keys, counts))
+	assert.DeepEqual(t, counts, []float64{2, 0})
+
+	// a new key counts fully
+	counts = []float64{3, 2, 5}
+	assert.That(t, tr.Deltas("s", append(keys, "c"), counts))
+	assert.DeepEqual(t, counts, []float64{0, 0, 5})
+
+	// a reset leaves the counts alone
+	counts = []float64{1, 1, 1}
+	assert.That(t, tr.Deltas("s", append(keys, "c"), counts))
+	assert.DeepEqual(t, counts, []float64{1, 1, 1})
+}
+
+func TestTrackerRate(t *testing.T) {
+	tr := NewTracker(0)
+	now := time.Now()
+
+	_, ok := tr.Rate("s", 10, now)
+	assert.That(t, !ok)
+
+	_, ok = tr.Rate("s", 20, now)
+	assert.That(t, !ok)
+
+	rate, ok := tr.Rate("s", 40, now.Add(10*time.Second))
+	assert.That(t, ok)
+	assert.Equal(t, rate, 2.0)
+
+	rate, ok = tr.Rate("s", 10, now.Add(20*time.Second))
+	assert.That(t, ok)
+	assert.Equal(t, rate, 1.0)
+}
+
+func TestTrackerExpire(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	at := time.Now()
+
+	_, ok := tr.Rate("old", 10, at)
+	assert.That(t, !ok)
+	assert.That(t, !tr.Deltas("old", []string{"a"}, []float64{1}))
+
+	now = now.Add(30 * time.Second)
+	_, ok = tr.Rate("new", 10, at)
+	assert.That(t, !ok)
+
+	// old has not been seen for the ttl, but new has
+	now = now.Add(45 * time.Second)
+	_, ok = tr.Rate("new", 20, at.Add(time.Second))
+	assert.That(t, ok)
+	_, ok = tr.Rate("old", 20, at.Add(time.Second))
+	assert.That(t, !ok)
+	assert.That(t, !tr.Deltas("old", []string{"a"}, []float64{2}))
+}
@@ -0,0 +1,4 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package snappy provides a decoder for the snappy block format.
+package snappy
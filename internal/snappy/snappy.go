@@ -0,0 +1,152 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package snappy
+
+import (
+	"encoding/binary"
+
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors originating at this package.
+var Error = errs.Class("snappy")
+
+// tag types in the low two bits of an element's tag byte.
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+)
+
+// maxExpansion bounds how many decoded bytes each byte of input can produce.
+// the most is a 3 byte copy of 64 bytes.
+const maxExpansion = 22
+
+// Decode returns the decoded form of the snappy block in src, reusing the
+// storage of dst if possible. Decoded blocks larger than max bytes are
+// rejected, unless max is zero.
+func Decode(dst, src []byte, max int) ([]byte, error) {
+	n, width := binary.Uvarint(src)
+	if width <= 0 || n > 0xffffffff {
+		return nil, Error.New("invalid length preamble")
+	}
+	if max > 0 && n > uint64(max) {
+		return nil, Error.New("decoded length too large: %d", n)
+	}
+	src = src[width:]
+
+	// reject lengths the input could never produce so that a tiny block
+	// can't make us allocate the maximum.
+	if n > uint64(len(src))*maxExpansion {
+		return nil, Error.New("corrupt input")
+	}
+
+	if uint64(cap(dst)) < n {
+		dst = make([]byte, 0, n)
+	}
+	dst = dst[:0]
+
+	for len(src) > 0 {
+		tag := src[0]
+
+		var length, offset int
+		switch tag & 0x03 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			src = src[1:]
+
+			// lengths of 60 and over are stored in the following 1 to 4
+			// bytes.
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, Error.New("corrupt input")
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+
+			if length <= 0 || len(src) < length ||
+				uint64(len(dst)+length) > n {
+
+				return nil, Error.New("corrupt input")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+
+		case tagCopy1:
+			if len(src) < 2 {
+				return nil, Error.New("corrupt input")
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+
+		case tagCopy2:
+			if len(src) < 3 {
+				return nil, Error.New("corrupt input")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:3]))
+			src = src[3:]
+
+		case tagCopy4:
+			if len(src) < 5 {
+				return nil, Error.New("corrupt input")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:5]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, Error.New("corrupt input")
+		}
+
+		// copies may overlap with the bytes they produce, so they must be
+		// done a byte at a time.
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, Error.New("corrupt input")
+	}
+	return dst, nil
+}
+
+// EncodeLiteral returns a valid snappy block for the src that is made of
+// only literals and so does no compression. It is useful for tests.
+func EncodeLiteral(dst, src []byte) []byte {
+	dst = dst[:0]
+
+	var buf [binary.MaxVarintLen64]byte
+	dst = append(dst, buf[:binary.PutUvarint(buf[:], uint64(len(src)))]...)
+
+	for len(src) > 0 {
+		chunk := src
+		if len(chunk) > 1<<16 {
+			chunk = chunk[:1<<16]
+		}
+		src = src[len(chunk):]
+
+		if n := len(chunk) - 1; n < 60 {
+			dst = append(dst, byte(n)<<2|tagLiteral)
+		} else if n < 1<<8 {
+			dst = append(dst, 60<<2|tagLiteral, byte(n))
+		} else {
+			dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+		}
+		dst = append(dst, chunk...)
+	}
+
+	return dst
+}
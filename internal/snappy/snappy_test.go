@@ -0,0 +1,69 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package snappy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestDecode(t *testing.T) {
+	decode := func(src string) string {
+		t.Helper()
+		out, err := Decode(nil, []byte(src), 0)
+		assert.NoError(t, err)
+		return string(out)
+	}
+
+	assert.Equal(t, decode("\x00"), "")
+	assert.Equal(t, decode("\x03\x08abc"), "abc")
+	assert.Equal(t, decode("\x0c\x08abc\x15\x03"), "abcabcabcabc")
+	assert.Equal(t, decode("\x0c\x08abc\x22\x03\x00"), "abcabcabcabc")
+	assert.Equal(t, decode("\x0c\x08abc\x23\x03\x00\x00\x00"), "abcabcabcabc")
+
+	for _, bad := range []string{"", "\x04\x08abc", "\x03\x08ab",
+		"\x0c\x08abc\x15\x04", "\x0c\x08abc\x15", "\xff",
+		"\x80\x01\x15\x01"} {
+
+		_, err := Decode(nil, []byte(bad), 0)
+		assert.Error(t, err)
+	}
+
+	_, err := Decode(nil, []byte("\x03\x08abc"), 2)
+	assert.Error(t, err)
+}
+
+func TestEncodeLiteral(t *testing.T) {
+	for _, size := range []int{0, 1, 59, 60, 61, 255, 256, 257, 1 << 16,
+		1<<16 + 1, 1 << 18} {
+
+		src := bytes.Repeat([]byte("x"), size)
+		out, err := Decode(nil, EncodeLiteral(nil, src), 0)
+		assert.NoError(t, err)
+		assert.That(t, bytes.Equal(out, src))
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{"\x00", "\x03\x08abc", "\x0c\x08abc\x15\x03",
+		"\x0c\x08abc\x22\x03\x00", "\x0c\x08abc\x23\x03\x00\x00\x00",
+		"\x0c\x08abc\x15\x04", "\xff"} {
+
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		out, err := Decode(nil, src, 1<<20)
+		if err != nil {
+			return
+		}
+		assert.That(t, len(out) <= 1<<20)
+		assert.That(t, len(out) <= len(src)*maxExpansion)
+
+		again, err := Decode(nil, EncodeLiteral(nil, out), 0)
+		assert.NoError(t, err)
+		assert.That(t, bytes.Equal(again, out))
+	})
+}
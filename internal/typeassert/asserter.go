@@ -91,6 +91,19 @@ func (a *Asserter) N(index int) *Asserter {
 	return a.a(m[index], path)
 }
 
+// Len asserts the value as a []interface{} and returns its length.
+func (a *Asserter) Len() int {
+	if *a.err != nil || a.x == nil {
+		return 0
+	}
+	m, ok := a.x.([]interface{})
+	if !ok {
+		*a.err = errs.New("invalid type: []interface{} != %T at %s",
+			a.x, a.path)
+	}
+	return len(m)
+}
+
 // Int asserts the value as an int.
 func (a *Asserter) Int() int {
 	if *a.err != nil || a.x == nil {
@@ -30,6 +30,8 @@ func TestAsserter(t *testing.T) {
 		assert.Equal(t, a.I("list").N(0).Int(), 2)
 		assert.Equal(t, a.I("list").N(1).Bool(), true)
 		assert.Equal(t, a.I("list").N(2).String(), "foo")
+		assert.Equal(t, a.I("list").Len(), 3)
+		assert.Equal(t, a.I("missing").Len(), 0)
 		assert.Equal(t, a.I("map").I("int").Int(), 2)
 		assert.NoError(t, a.Err())
 	})
@@ -65,5 +67,11 @@ func TestAsserter(t *testing.T) {
 			assert.Error(t, a.Err())
 		}
 
+		{
+			a := A(data)
+			a.I("map").Len()
+			assert.Error(t, a.Err())
+		}
+
 	})
 }
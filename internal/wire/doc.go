@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package wire provides helpers for reading and writing the protobuf wire
+// format without any generated code.
+package wire
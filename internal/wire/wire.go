@@ -0,0 +1,216 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package wire
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/zeebo/errs"
+)
+
+// Error wraps all of the errors originating at this package.
+var Error = errs.Class("wire")
+
+// Type is the wire type of a field.
+type Type int
+
+// These are the wire types that are supported.
+const (
+	Varint  Type = 0
+	Fixed64 Type = 1
+	Bytes   Type = 2
+	Fixed32 Type = 5
+)
+
+// Field is a field read from a protobuf message.
+type Field struct {
+	Num  int
+	Type Type
+
+	// Int is set for Varint, Fixed64 and Fixed32 fields.
+	Int uint64
+
+	// Data is set for Bytes fields. It aliases the message.
+	Data []byte
+}
+
+// Next reads the next field out of the message and returns the rest.
+func Next(msg []byte) (f Field, rest []byte, err error) {
+	key, n := binary.Uvarint(msg)
+	if n <= 0 {
+		return f, nil, Error.New("invalid field key")
+	}
+	msg = msg[n:]
+
+	f.Num = int(key >> 3)
+	f.Type = Type(key & 7)
+	if f.Num <= 0 {
+		return f, nil, Error.New("invalid field number: %d", f.Num)
+	}
+
+	switch f.Type {
+	case Varint:
+		f.Int, n = binary.Uvarint(msg)
+		if n <= 0 {
+			return f, nil, Error.New("invalid varint")
+		}
+		msg = msg[n:]
+
+	case Fixed64:
+		if len(msg) < 8 {
+			return f, nil, Error.New("truncated fixed64")
+		}
+		f.Int = binary.LittleEndian.Uint64(msg)
+		msg = msg[8:]
+
+	case Fixed32:
+		if len(msg) < 4 {
+			return f, nil, Error.New("truncated fixed32")
+		}
+		f.Int = uint64(binary.LittleEndian.Uint32(msg))
+		msg = msg[4:]
+
+	case Bytes:
+		size, n := binary.Uvarint(msg)
+		if n <= 0 || size > uint64(len(msg)-n) {
+			return f, nil, Error.New("invalid length")
+		}
+		f.Data = msg[n : n+int(size)]
+		msg = msg[n+int(size):]
+
+	default:
+		return f, nil, Error.New("unsupported wire type: %d", f.Type)
+	}
+
+	return f, msg, nil
+}
+
+// Iterate calls the callback with every field in the message, stopping at
+// the first error.
+func Iterate(msg []byte, cb func(f Field) error) (err error) {
+	for len(msg) > 0 {
+		var f Field
+		f, msg, err = Next(msg)
+		if err != nil {
+			return err
+		}
+		if err := cb(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the field as a string.
+func (f Field) String() string { return string(f.Data) }
+
+// Int64 returns the field as an int64.
+func (f Field) Int64() int64 { return int64(f.Int) }
+
+// Sint64 returns the field as a zig-zag encoded sint64.
+func (f Field) Sint64() int64 { return unzigzag(f.Int) }
+
+// Double returns the field as a double.
+func (f Field) Double() float64 { return math.Float64frombits(f.Int) }
+
+// Float returns the field as a float.
+func (f Field) Float() float64 {
+	return float64(math.Float32frombits(uint32(f.Int)))
+}
+
+// unzigzag decodes a zig-zag encoded value.
+func unzigzag(x uint64) int64 {
+	return int64(x>>1) ^ -int64(x&1)
+}
+
+// PackedVarints calls the callback with every varint in the packed data.
+// It also accepts an unpacked Varint field.
+func (f Field) PackedVarints(cb func(x uint64)) error {
+	if f.Type == Varint {
+		cb(f.Int)
+		return nil
+	}
+	if f.Type != Bytes {
+		return Error.New("invalid type for packed varints: %d", f.Type)
+	}
+	for data := f.Data; len(data) > 0; {
+		x, n := binary.Uvarint(data)
+		if n <= 0 {
+			return Error.New("invalid varint")
+		}
+		cb(x)
+		data = data[n:]
+	}
+	return nil
+}
+
+// PackedSint64s calls the callback with every zig-zag encoded value in the
+// packed data. It also accepts an unpacked Varint field.
+func (f Field) PackedSint64s(cb func(x int64)) error {
+	return f.PackedVarints(func(x uint64) { cb(unzigzag(x)) })
+}
+
+// PackedFixed64s calls the callback with every fixed64 in the packed data.
+// It also accepts an unpacked Fixed64 field.
+func (f Field) PackedFixed64s(cb func(x uint64)) error {
+	if f.Type == Fixed64 {
+		cb(f.Int)
+		return nil
+	}
+	if f.Type != Bytes || len(f.Data)%8 != 0 {
+		return Error.New("invalid packed fixed64s")
+	}
+	for data := f.Data; len(data) > 0; data = data[8:] {
+		cb(binary.LittleEndian.Uint64(data))
+	}
+	return nil
+}
+
+// PackedDoubles calls the callback with every double in the packed data.
+// It also accepts an unpacked Fixed64 field.
+func (f Field) PackedDoubles(cb func(x float64)) error {
+	return f.PackedFixed64s(func(x uint64) { cb(math.Float64frombits(x)) })
+}
+
+//
+// appending
+//
+
+// AppendKey appends the key for the field number and type.
+func AppendKey(buf []byte, num int, typ Type) []byte {
+	return AppendVarint(buf, uint64(num)<<3|uint64(typ))
+}
+
+// AppendVarint appends a varint.
+func AppendVarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+// AppendSint64 appends a zig-zag encoded varint.
+func AppendSint64(buf []byte, x int64) []byte {
+	return AppendVarint(buf, uint64(x<<1)^uint64(x>>63))
+}
+
+// AppendFixed64 appends a fixed64.
+func AppendFixed64(buf []byte, x uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], x)
+	return append(buf, tmp[:]...)
+}
+
+// AppendDouble appends a double.
+func AppendDouble(buf []byte, x float64) []byte {
+	return AppendFixed64(buf, math.Float64bits(x))
+}
+
+// AppendBytes appends length delimited data.
+func AppendBytes(buf []byte, data []byte) []byte {
+	return append(AppendVarint(buf, uint64(len(data))), data...)
+}
+
+// AppendString appends a length delimited string.
+func AppendString(buf []byte, data string) []byte {
+	return append(AppendVarint(buf, uint64(len(data))), data...)
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestWire(t *testing.T) {
+	var msg []byte
+	msg = AppendVarint(AppendKey(msg, 1, Varint), 150)
+	msg = AppendSint64(AppendKey(msg, 2, Varint), -3)
+	msg = AppendDouble(AppendKey(msg, 3, Fixed64), 1.5)
+	msg = AppendString(AppendKey(msg, 4, Bytes), "hello")
+
+	var packed []byte
+	packed = AppendSint64(packed, 1)
+	packed = AppendSint64(packed, -1)
+	packed = AppendSint64(packed, 300)
+	msg = AppendBytes(AppendKey(msg, 5, Bytes), packed)
+
+	var doubles []byte
+	doubles = AppendDouble(doubles, 1)
+	doubles = AppendDouble(doubles, 2)
+	msg = AppendBytes(AppendKey(msg, 6, Bytes), doubles)
+
+	var fields []int
+	assert.NoError(t, Iterate(msg, func(f Field) error {
+		fields = append(fields, f.Num)
+		switch f.Num {
+		case 1:
+			assert.Equal(t, f.Int64(), int64(150))
+		case 2:
+			assert.Equal(t, f.Sint64(), int64(-3))
+		case 3:
+			assert.Equal(t, f.Double(), 1.5)
+		case 4:
+			assert.Equal(t, f.String(), "hello")
+		case 5:
+			var got []int64
+			assert.NoError(t, f.PackedSint64s(func(x int64) {
+				got = append(got, x)
+			}))
+			assert.DeepEqual(t, got, []int64{1, -1, 300})
+		case 6:
+			var got []float64
+			assert.NoError(t, f.PackedDoubles(func(x float64) {
+				got = append(got, x)
+			}))
+			assert.DeepEqual(t, got, []float64{1, 2})
+		}
+		return nil
+	}))
+	assert.DeepEqual(t, fields, []int{1, 2, 3, 4, 5, 6})
+
+	for _, bad := range []string{"\x08", "\x09\x00", "\x0a\x05abc", "\x0b",
+		"\x00\x00"} {
+
+		assert.Error(t, Iterate([]byte(bad), func(Field) error { return nil }))
+	}
+}
+
+func FuzzIterate(f *testing.F) {
+	var msg []byte
+	msg = AppendVarint(AppendKey(msg, 1, Varint), 150)
+	msg = AppendDouble(AppendKey(msg, 3, Fixed64), 1.5)
+	msg = AppendString(AppendKey(msg, 4, Bytes), "hello")
+	msg = AppendBytes(AppendKey(msg, 5, Bytes), AppendSint64(nil, -1))
+	f.Add(msg)
+	for _, bad := range []string{"\x08", "\x09\x00", "\x0a\x05abc", "\x0b",
+		"\x00\x00"} {
+
+		f.Add([]byte(bad))
+	}
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		size := 0
+		_ = Iterate(msg, func(f Field) error {
+			assert.That(t, f.Num > 0)
+			assert.That(t, len(f.Data) <= len(msg))
+			size += len(f.Data)
+
+			_ = f.PackedVarints(func(uint64) {})
+			_ = f.PackedFixed64s(func(uint64) {})
+			return nil
+		})
+		assert.That(t, size <= len(msg))
+	})
+}
@@ -1,4 +1,5 @@
 // Copyright (C) 2018. See AUTHORS.
 
-// package graphite provides a listener for the graphite wire protocol.
+// package graphite provides listeners for the graphite plaintext and pickle
+// wire protocols.
 package graphite
@@ -6,107 +6,132 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"math"
 	"net"
 	"strconv"
-	"sync"
+	"time"
 
 	"github.com/vivint/rothko/data"
 	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
 	"github.com/zeebo/errs"
 )
 
+// LatePolicy controls what happens to values with a timestamp before the
+// start of the Writer's current set of records, or too far in the future.
+type LatePolicy = listener.LatePolicy
+
+const (
+	// LateAccept ignores timestamps entirely, adding every value to the
+	// current set of records as if it happened now. No policy adds a value
+	// to the set of records its timestamp belongs in.
+	LateAccept = listener.LateAccept
+
+	// LateSeparate adds late values into a separate metric named by adding
+	// the LateSuffix to the metric name.
+	LateSeparate = listener.LateSeparate
+
+	// LateDrop discards late values, keeping count of how many were dropped.
+	LateDrop = listener.LateDrop
+)
+
+// Options controls the behavior of the graphite listener.
+type Options struct {
+	// Late controls what happens to values that are late. Defaults to
+	// LateAccept, which ignores timestamps.
+	Late LatePolicy
+
+	// Tolerance is how far before the start of the current set of records,
+	// or after the current time, a timestamp is allowed to be without being
+	// considered late.
+	Tolerance time.Duration
+
+	// LateSuffix is appended to the metric name for late values when the
+	// policy is LateSeparate. Defaults to ".late".
+	LateSuffix string
+
+	// Protocol is the network protocol to listen with, either "tcp" or
+	// "udp". Defaults to "tcp".
+	Protocol string
+
+	// Bufsize is the size of the buffer used to read udp datagrams. Defaults
+	// to 65535.
+	Bufsize int
+
+	// MaxLines is the maximum number of lines handled from a single udp
+	// datagram. Any further lines are dropped. If zero, there is no limit.
+	MaxLines int
+
+	// IdTag is the key of a graphite tag that, if set, is removed from the
+	// metric name and used as the id of the observation. An id given as the
+	// optional fourth field of a line takes precedence.
+	IdTag string
+}
+
 // Listener implements the listener.Listener for the graphite wire protocol.
 type Listener struct {
 	address string
+	opts    Options
+	late    *listener.Late
 }
 
 // New returns a Listener that when Run will listen on the provided address.
-func New(address string) *Listener {
-	return &Listener{
-		address: address,
+func New(address string, opts Options) *Listener {
+	if opts.Late == "" {
+		opts.Late = LateAccept
 	}
-}
-
-// Run listens on the address and writes all of the metrics to the writer.
-func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
-	lis, err := net.Listen("tcp", l.address)
-	if err != nil {
-		return errs.Wrap(err)
+	if opts.LateSuffix == "" {
+		opts.LateSuffix = ".late"
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = "tcp"
+	}
+	if opts.Bufsize == 0 {
+		opts.Bufsize = 65535
 	}
-	defer lis.Close()
-
-	var wg sync.WaitGroup
-	var errs = make(chan error, 1)
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		errs <- handleListener(ctx, w, lis)
-	}()
 
-	select {
-	case err := <-errs:
-		return err
-	case <-ctx.Done():
-		lis.Close()
-		wg.Wait()
-		return nil
+	return &Listener{
+		address: address,
+		opts:    opts,
+		late: &listener.Late{
+			Policy:    opts.Late,
+			Tolerance: opts.Tolerance,
+			Suffix:    opts.LateSuffix,
+			Name:      "graphite",
+		},
 	}
 }
 
-// handleListener accepts connections from the listener and spawns handlers
-// for them.
-func handleListener(ctx context.Context, w *data.Writer, lis net.Listener) (
-	err error) {
-
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// Dropped returns how many late values have been dropped.
+func (l *Listener) Dropped() int64 {
+	return l.late.Dropped()
+}
 
-	for {
-		conn, err := lis.Accept()
-		if err != nil {
-			return err
-		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			err := handleConn(ctx, w, conn)
-			if err != nil {
-				external.Errorw("graphite connection error",
-					"err", err.Error(),
-				)
-			}
-		}()
+// Run listens on the address and writes all of the metrics to the writer.
+func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
+	switch l.opts.Protocol {
+	case "tcp":
+		return listener.RunTCP(ctx, l.address,
+			func(ctx context.Context, conn net.Conn) error {
+				return l.handleConn(ctx, w, conn)
+			})
+	case "udp":
+		return listener.RunUDP(ctx, l.address, l.opts.Bufsize,
+			func(packet []byte, addr net.Addr) {
+				l.handlePacket(ctx, w, packet, addr)
+			})
+	default:
+		return errs.New("unknown protocol: %q", l.opts.Protocol)
 	}
 }
 
 // handleConn handles lines from the connection and adds them to the writer.
-func handleConn(ctx context.Context, w *data.Writer, conn net.Conn) (
-	err error) {
-
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		conn.Close()
-	}()
-
-	// TODO(jeff): don't return an error when the conn is closed due to the
-	// context.
+func (l *Listener) handleConn(ctx context.Context, w *data.Writer,
+	conn net.Conn) (err error) {
 
 	scanner := bufio.NewScanner(conn)
 	for scanner.Scan() {
-		err := handleLine(ctx, w, scanner.Bytes())
+		err := l.handleLine(ctx, w, scanner.Bytes())
 		if err != nil {
 			external.Errorw("invalid graphite line",
 				"line", scanner.Text(),
@@ -118,10 +143,14 @@ func handleConn(ctx context.Context, w *data.Writer, conn net.Conn) (
 	return scanner.Err()
 }
 
-// handleLine adds the graphite data in the line to the writer.
-func handleLine(ctx context.Context, w *data.Writer, line []byte) (err error) {
+// handleLine adds the graphite data in the line to the writer. The line may
+// have an optional fourth field holding the id of the observation. The
+// timestamp is only parsed if the late policy needs it.
+func (l *Listener) handleLine(ctx context.Context, w *data.Writer,
+	line []byte) (err error) {
+
 	fields := bytes.Split(line, []byte{' '})
-	if len(fields) != 3 {
+	if len(fields) != 3 && len(fields) != 4 {
 		return errs.New("bad number of fields: %d", len(fields))
 	}
 
@@ -130,6 +159,48 @@ func handleLine(ctx context.Context, w *data.Writer, line []byte) (err error) {
 		return errs.Wrap(err)
 	}
 
-	w.Add(ctx, string(fields[0]), value, nil)
+	timestamp := -1.0
+	if l.opts.Late != LateAccept {
+		timestamp, err = strconv.ParseFloat(string(fields[2]), 64)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	metric, err := listener.CanonicalName(string(fields[0]))
+	if err != nil {
+		return err
+	}
+
+	// an empty fourth field, like from a trailing space, is not an id.
+	var id []byte
+	if len(fields) == 4 && len(fields[3]) > 0 {
+		id = fields[3]
+	}
+
+	l.add(ctx, w, metric, value, timestamp, id)
 	return nil
 }
+
+// add adds the value to the writer, applying the late policy based on the
+// timestamp. Negative timestamps are treated as the current time. If the id
+// tag is configured, it is removed from the metric and used as the id when
+// one isn't provided.
+func (l *Listener) add(ctx context.Context, w *data.Writer, metric string,
+	value, timestamp float64, id []byte) {
+
+	if l.opts.IdTag != "" {
+		var tag string
+		metric, tag = extractTag(metric, l.opts.IdTag)
+		if id == nil && tag != "" {
+			id = []byte(tag)
+		}
+	}
+
+	var at time.Time
+	if timestamp >= 0 {
+		secs, frac := math.Modf(timestamp)
+		at = time.Unix(int64(secs), int64(frac*1e9))
+	}
+	l.late.Add(ctx, w, metric, at, value, id)
+}
@@ -5,9 +5,11 @@ package graphite
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vivint/rothko/data"
 	"github.com/vivint/rothko/dist"
@@ -23,9 +25,77 @@ func TestListener(t *testing.T) {
 		"test.foo.baz 123 0",
 		"test.foo.bif 123 0",
 		"test.foo.zoo 123 0",
+		"test.foo.tag;b=2;a=1 123 0",
+		"test.foo.tag;a=1;b=2 123 0",
+		"test.foo.bad;a 123 0",
 	}, "\n"))
 
-	assert.NoError(t, handleConn(ctx, w, newFakeConn(lines)))
+	l := New("", Options{})
+	assert.NoError(t, l.handleConn(ctx, w, newFakeConn(lines)))
+
+	names := make(map[string]bool)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			names[name] = true
+			return true
+		})
+
+	assert.DeepEqual(t, names, map[string]bool{
+		"test.foo.bar":         true,
+		"test.foo.baz":         true,
+		"test.foo.bif":         true,
+		"test.foo.zoo":         true,
+		"test.foo.tag;a=1;b=2": true,
+	})
+}
+
+func TestListenerIds(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+
+	lines := []byte(strings.Join([]string{
+		"test.foo 1 0 low",
+		"test.foo 5 0",
+		"test.foo 0 0 ",
+		"test.foo;device=high 9 0",
+		"test.foo;device=ignored 7 0 given",
+		"test.foo 1 0 2 3",
+	}, "\n"))
+
+	l := New("", Options{IdTag: "device"})
+	assert.NoError(t, l.handleConn(ctx, w, newFakeConn(lines)))
+
+	type result struct {
+		obs          int64
+		minId, maxId string
+		exemplars    int
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{
+				rec.Observations, string(rec.MinId), string(rec.MaxId),
+				len(rec.Exemplars)}
+			return true
+		})
+
+	// the line with a trailing space has no id, so it is not an exemplar.
+	assert.DeepEqual(t, got, map[string]result{
+		"test.foo": {5, "", "high", 3},
+	})
+}
+
+func TestListenerUDP(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l := New("", Options{Protocol: "udp", MaxLines: 3})
+
+	l.handlePacket(ctx, w, []byte("test.foo.bar 123 0\r\n\n"+
+		"test.foo.baz 123 0\ntest.foo.bif 123 0"), &net.UDPAddr{})
+	l.handlePacket(ctx, w, []byte("test.foo.zoo 123 0\n"+
+		"test.foo.zip 123 0\ntest.foo.zap 123 0\ntest.foo.dropped 123 0"),
+		&net.UDPAddr{})
 
 	names := make(map[string]bool)
 	w.Capture(ctx,
@@ -37,9 +107,82 @@ func TestListener(t *testing.T) {
 	assert.DeepEqual(t, names, map[string]bool{
 		"test.foo.bar": true,
 		"test.foo.baz": true,
-		"test.foo.bif": true,
 		"test.foo.zoo": true,
+		"test.foo.zip": true,
+		"test.foo.zap": true,
+	})
+}
+
+func TestListenerLate(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(opts Options) (map[string]bool, int64) {
+		w := data.NewWriter(fakeParams{})
+		l := New("", opts)
+
+		// the first add creates the current set of records, so that the
+		// following lines can be compared against its start.
+		w.Add(ctx, "test.first", 123, nil)
+		time.Sleep(time.Millisecond)
+
+		now := float64(time.Now().UnixNano()) / 1e9
+		lines := []byte(strings.Join([]string{
+			fmt.Sprintf("test.now 123 %f", now),
+			fmt.Sprintf("test.old 123 %f", now-60),
+			fmt.Sprintf("test.future 123 %f", now+60),
+			"test.unset 123 -1",
+			"test.invalid 123 never",
+		}, "\n"))
+		assert.NoError(t, l.handleConn(ctx, w, newFakeConn(lines)))
+
+		names := make(map[string]bool)
+		w.Capture(ctx,
+			func(ctx context.Context, name string, rec data.Record) bool {
+				names[name] = true
+				return true
+			})
+		return names, l.Dropped()
+	}
+
+	// timestamps are not even parsed when late values are accepted.
+	names, dropped := run(Options{Late: LateAccept})
+	assert.DeepEqual(t, names, map[string]bool{
+		"test.first":   true,
+		"test.now":     true,
+		"test.old":     true,
+		"test.future":  true,
+		"test.unset":   true,
+		"test.invalid": true,
+	})
+	assert.Equal(t, dropped, int64(0))
+
+	names, dropped = run(Options{Late: LateSeparate})
+	assert.DeepEqual(t, names, map[string]bool{
+		"test.first":       true,
+		"test.now":         true,
+		"test.old.late":    true,
+		"test.future.late": true,
+		"test.unset":       true,
+	})
+	assert.Equal(t, dropped, int64(0))
+
+	names, dropped = run(Options{Late: LateDrop})
+	assert.DeepEqual(t, names, map[string]bool{
+		"test.first": true,
+		"test.now":   true,
+		"test.unset": true,
+	})
+	assert.Equal(t, dropped, int64(2))
+
+	names, dropped = run(Options{Late: LateDrop, Tolerance: time.Hour})
+	assert.DeepEqual(t, names, map[string]bool{
+		"test.first":  true,
+		"test.now":    true,
+		"test.old":    true,
+		"test.future": true,
+		"test.unset":  true,
 	})
+	assert.Equal(t, dropped, int64(0))
 }
 
 //
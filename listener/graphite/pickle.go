@@ -0,0 +1,145 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// maxPickleSize is the largest pickle frame that will be accepted.
+const maxPickleSize = 1 << 20
+
+// PickleListener implements the listener.Listener for the graphite pickle
+// protocol, as sent by carbon-relay.
+type PickleListener struct {
+	lis *Listener
+}
+
+// NewPickle returns a PickleListener that when Run will listen on the
+// provided address. The pickle protocol is only supported over tcp, so the
+// Protocol option is ignored.
+func NewPickle(address string, opts Options) *PickleListener {
+	opts.Protocol = "tcp"
+	return &PickleListener{
+		lis: New(address, opts),
+	}
+}
+
+// Dropped returns how many late values have been dropped.
+func (p *PickleListener) Dropped() int64 {
+	return p.lis.Dropped()
+}
+
+// Run listens on the address and writes all of the metrics to the writer.
+func (p *PickleListener) Run(ctx context.Context, w *data.Writer) (err error) {
+	return listener.RunTCP(ctx, p.lis.address,
+		func(ctx context.Context, conn net.Conn) error {
+			return p.handleConn(ctx, w, conn)
+		})
+}
+
+// handleConn handles pickle frames from the connection and adds them to the
+// writer.
+func (p *PickleListener) handleConn(ctx context.Context, w *data.Writer,
+	conn net.Conn) (err error) {
+
+	var header [4]byte
+	var frame []byte
+	r := bufio.NewReader(conn)
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errs.Wrap(err)
+		}
+
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxPickleSize {
+			return errs.New("pickle frame too large: %d", size)
+		}
+		if uint32(cap(frame)) < size {
+			frame = make([]byte, size)
+		}
+		frame = frame[:size]
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return errs.Wrap(err)
+		}
+
+		err := p.handleFrame(ctx, w, frame, conn.RemoteAddr())
+		if err != nil {
+			external.Errorw("invalid graphite pickle",
+				"peer", conn.RemoteAddr().String(),
+				"err", err.Error(),
+			)
+		}
+	}
+}
+
+// handleFrame adds the graphite data in the pickle frame to the writer. The
+// frame is expected to contain a list of (path, (timestamp, value)) tuples.
+// Invalid datapoints are logged and skipped, so that they do not stop the
+// rest of the frame from being added.
+func (p *PickleListener) handleFrame(ctx context.Context, w *data.Writer,
+	frame []byte, peer net.Addr) (err error) {
+
+	val, err := unpickle(frame)
+	if err != nil {
+		return err
+	}
+	list, ok := val.(*pickleList)
+	if !ok {
+		return errs.New("pickle is not a list: %T", val)
+	}
+
+	for _, item := range list.items {
+		path, timestamp, value, err := pickleDatapoint(item)
+		if err == nil {
+			path, err = listener.CanonicalName(path)
+		}
+		if err != nil {
+			external.Errorw("invalid graphite pickle datapoint",
+				"peer", peer.String(),
+				"err", err.Error(),
+			)
+			continue
+		}
+
+		p.lis.add(ctx, w, path, value, timestamp, nil)
+	}
+
+	return nil
+}
+
+// pickleDatapoint pulls the path, timestamp and value out of an unpickled
+// (path, (timestamp, value)) tuple.
+func pickleDatapoint(item interface{}) (path string, timestamp, value float64,
+	err error) {
+
+	outer, ok := item.(pickleTuple)
+	if !ok || len(outer) != 2 {
+		return "", 0, 0, errs.New("invalid datapoint: %v", item)
+	}
+	inner, ok := outer[1].(pickleTuple)
+	if !ok || len(inner) != 2 {
+		return "", 0, 0, errs.New("invalid datapoint: %v", item)
+	}
+
+	path, ok1 := outer[0].(string)
+	timestamp, ok2 := inner[0].(float64)
+	value, ok3 := inner[1].(float64)
+	if !ok1 || !ok2 || !ok3 {
+		return "", 0, 0, errs.New("invalid datapoint: %v", item)
+	}
+
+	return path, timestamp, value, nil
+}
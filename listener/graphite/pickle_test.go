@@ -0,0 +1,129 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestUnpickle(t *testing.T) {
+	type point struct {
+		path             string
+		timestamp, value float64
+	}
+
+	load := func(pickle string) []point {
+		t.Helper()
+
+		val, err := unpickle([]byte(pickle))
+		assert.NoError(t, err)
+		list, ok := val.(*pickleList)
+		assert.That(t, ok)
+
+		var points []point
+		for _, item := range list.items {
+			path, timestamp, value, err := pickleDatapoint(item)
+			assert.NoError(t, err)
+			points = append(points, point{path, timestamp, value})
+		}
+		return points
+	}
+
+	expected := []point{
+		{"foo.bar", 1530000000, 1.5},
+		{"foo.baz", 1530000000.5, 2},
+		{"foo.big", 1530000000, math.Pow(2, 70)},
+		{"foo.tag;b=2;a=1", 1530000000, -3},
+	}
+
+	// generated by python3 pickle.dumps with protocols 0, 2 and 4.
+	assert.DeepEqual(t, load("(lp0\n(Vfoo.bar\np1\n(I1530000000\nF1.5\ntp2\n"+
+		"tp3\na(Vfoo.baz\np4\n(F1530000000.5\nI2\ntp5\ntp6\na(Vfoo.big\n"+
+		"p7\n(I1530000000\nL1180591620717411303424L\ntp8\ntp9\na"+
+		"(Vfoo.tag;b=2;a=1\np10\n(I1530000000\nI-3\ntp11\ntp12\na."),
+		expected)
+
+	assert.DeepEqual(t, load("\x80\x02]q\x00(X\x07\x00\x00\x00foo.barq\x01"+
+		"J\x80\xf21[G?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03"+
+		"X\x07\x00\x00\x00foo.bazq\x04GA\xd6\xcc|\xa0 \x00\x00K\x02\x86q"+
+		"\x05\x86q\x06X\x07\x00\x00\x00foo.bigq\x07J\x80\xf21[\x8a\t\x00"+
+		"\x00\x00\x00\x00\x00\x00\x00@\x86q\x08\x86q\tX\x0f\x00\x00\x00"+
+		"foo.tag;b=2;a=1q\nJ\x80\xf21[J\xfd\xff\xff\xff\x86q\x0b\x86q\x0ce."),
+		expected)
+
+	assert.DeepEqual(t, load("\x80\x04\x95x\x00\x00\x00\x00\x00\x00\x00]"+
+		"\x94(\x8c\x07foo.bar\x94J\x80\xf21[G?\xf8\x00\x00\x00\x00\x00\x00"+
+		"\x86\x94\x86\x94\x8c\x07foo.baz\x94GA\xd6\xcc|\xa0 \x00\x00K\x02"+
+		"\x86\x94\x86\x94\x8c\x07foo.big\x94J\x80\xf21[\x8a\t\x00\x00\x00"+
+		"\x00\x00\x00\x00\x00@\x86\x94\x86\x94\x8c\x0ffoo.tag;b=2;a=1\x94"+
+		"J\x80\xf21[J\xfd\xff\xff\xff\x86\x94\x86\x94e."),
+		expected)
+
+	// python2 carbon sends byte strings.
+	assert.DeepEqual(t, load("\x80\x02]q\x00(U\x07foo.barq\x01K\x01K\x02"+
+		"\x86q\x02\x86q\x03h\x03e."),
+		[]point{{"foo.bar", 1, 2}, {"foo.bar", 1, 2}})
+
+	// anything that can construct objects is rejected, like python3 bytes
+	// pickled with protocol 2.
+	_, err := unpickle([]byte("\x80\x02]q\x00c_codecs\nencode\nq\x01" +
+		"X\x03\x00\x00\x00a.bq\x02X\x06\x00\x00\x00latin1q\x03\x86q\x04" +
+		"Rq\x05a."))
+	assert.Error(t, err)
+
+	for _, bad := range []string{"", "]", "(.", "a.", "\x86.", "h\x00.",
+		"X\xff\xff\xff\xff."} {
+
+		_, err := unpickle([]byte(bad))
+		assert.Error(t, err)
+	}
+}
+
+func TestPickleListener(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+
+	frame := func(pickle string) []byte {
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(pickle)))
+		return append(header[:], pickle...)
+	}
+
+	var conn []byte
+	conn = append(conn, frame("\x80\x02]q\x00(X\x07\x00\x00\x00foo.barq"+
+		"\x01K\x01K\x02\x86q\x02\x86q\x03X\x0f\x00\x00\x00foo.tag;b=2;a=1"+
+		"q\x04K\x01K\x02\x86q\x05\x86q\x06e.")...)
+	conn = append(conn, frame("\x80\x02]q\x00U\x03badq\x01a.")...)
+	conn = append(conn, frame("\x80\x02]q\x00(U\x07foo.bazq\x01K\x01K\x02"+
+		"\x86q\x02\x86q\x03e.")...)
+
+	// bad datapoints are skipped without dropping the rest of the frame.
+	conn = append(conn, frame("\x80\x02](U\x07foo.oneK\x01K\x02\x86\x86"+
+		"U\x03bad"+
+		"U\x05foo;aK\x01K\x02\x86\x86"+
+		"U\x07foo.twoK\x01K\x02\x86\x86e.")...)
+
+	p := NewPickle("", Options{})
+	assert.NoError(t, p.handleConn(ctx, w, newFakeConn(conn)))
+
+	names := make(map[string]bool)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			names[name] = true
+			return true
+		})
+
+	assert.DeepEqual(t, names, map[string]bool{
+		"foo.bar":         true,
+		"foo.baz":         true,
+		"foo.one":         true,
+		"foo.two":         true,
+		"foo.tag;a=1;b=2": true,
+	})
+}
@@ -4,21 +4,75 @@ package graphite
 
 import (
 	"context"
+	"time"
 
 	"github.com/vivint/rothko/internal/typeassert"
 	"github.com/vivint/rothko/listener"
 	"github.com/vivint/rothko/registry"
+	"github.com/zeebo/errs"
 )
 
 func init() {
 	registry.RegisterListener("graphite", registry.ListenerMakerFunc(
 		func(ctx context.Context, config interface{}) (listener.Listener, error) {
-			a := typeassert.A(config)
-			lis := New(a.I("address").String())
-			if err := a.Err(); err != nil {
+			address, opts, err := parseConfig(config)
+			if err != nil {
 				return nil, err
 			}
 
-			return lis, nil
+			return New(address, opts), nil
 		}))
+
+	registry.RegisterListener("graphite_pickle", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			address, opts, err := parseConfig(config)
+			if err != nil {
+				return nil, err
+			}
+
+			return NewPickle(address, opts), nil
+		}))
+}
+
+// parseConfig returns the address and options from the config.
+func parseConfig(config interface{}) (address string, opts Options,
+	err error) {
+
+	a := typeassert.A(config)
+	address = a.I("address").String()
+	late := a.I("late").String()
+	tolerance := a.I("tolerance").String()
+	late_suffix := a.I("late_suffix").String()
+	protocol := a.I("protocol").String()
+	bufsize := a.I("bufsize").Int64()
+	max_lines := a.I("max_lines").Int64()
+	id_tag := a.I("id_tag").String()
+	if err := a.Err(); err != nil {
+		return "", Options{}, err
+	}
+
+	opts = Options{
+		LateSuffix: late_suffix,
+		Protocol:   protocol,
+		Bufsize:    int(bufsize),
+		MaxLines:   int(max_lines),
+		IdTag:      id_tag,
+	}
+	opts.Late, err = listener.ParseLatePolicy(late)
+	if err != nil {
+		return "", Options{}, err
+	}
+	switch opts.Protocol {
+	case "", "tcp", "udp":
+	default:
+		return "", Options{}, errs.New("unknown protocol: %q", protocol)
+	}
+	if tolerance != "" {
+		opts.Tolerance, err = time.ParseDuration(tolerance)
+		if err != nil {
+			return "", Options{}, errs.Wrap(err)
+		}
+	}
+
+	return address, opts, nil
 }
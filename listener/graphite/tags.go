@@ -0,0 +1,42 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"strings"
+)
+
+// splitTags splits the metric into the name and the tags, where the tags
+// include the leading semicolon.
+func splitTags(metric string) (name, tags string) {
+	index := strings.IndexByte(metric, ';')
+	if index == -1 {
+		return metric, ""
+	}
+	return metric[:index], metric[index:]
+}
+
+// extractTag removes the tag with the key from the metric, returning the
+// metric without it and the tag's value. The value is empty if the metric
+// does not have the tag.
+func extractTag(metric, key string) (string, string) {
+	name, tags := splitTags(metric)
+	if tags == "" {
+		return metric, ""
+	}
+
+	prefix := ";" + key + "="
+	start := strings.Index(tags, prefix)
+	if start == -1 {
+		return metric, ""
+	}
+
+	end := strings.IndexByte(tags[start+1:], ';')
+	if end == -1 {
+		end = len(tags)
+	} else {
+		end += start + 1
+	}
+
+	return name + tags[:start] + tags[end:], tags[start+len(prefix) : end]
+}
@@ -0,0 +1,24 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestExtractTag(t *testing.T) {
+	f := func(metric, key string) [2]string {
+		t.Helper()
+		metric, value := extractTag(metric, key)
+		return [2]string{metric, value}
+	}
+
+	assert.Equal(t, f("cpu", "id"), [2]string{"cpu", ""})
+	assert.Equal(t, f("cpu;a=1", "id"), [2]string{"cpu;a=1", ""})
+	assert.Equal(t, f("cpu;id=x", "id"), [2]string{"cpu", "x"})
+	assert.Equal(t, f("cpu;a=1;id=x;z=2", "id"), [2]string{"cpu;a=1;z=2", "x"})
+	assert.Equal(t, f("cpu;a=1;id=x", "id"), [2]string{"cpu;a=1", "x"})
+	assert.Equal(t, f("cpu;aid=1;id=x", "id"), [2]string{"cpu;aid=1", "x"})
+}
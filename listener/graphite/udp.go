@@ -0,0 +1,49 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"net"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+)
+
+// handlePacket adds every line in the datagram to the writer, up to the
+// maximum number of lines.
+func (l *Listener) handlePacket(ctx context.Context, w *data.Writer,
+	packet []byte, addr net.Addr) {
+
+	for lines := 0; len(packet) > 0; lines++ {
+		if l.opts.MaxLines > 0 && lines >= l.opts.MaxLines {
+			external.Errorw("too many graphite lines in datagram",
+				"peer", addr.String(),
+				"max", l.opts.MaxLines,
+			)
+			return
+		}
+
+		var line []byte
+		if index := bytes.IndexByte(packet, '\n'); index >= 0 {
+			line, packet = packet[:index], packet[index+1:]
+		} else {
+			line, packet = packet, nil
+		}
+
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) == 0 {
+			continue
+		}
+
+		err := l.handleLine(ctx, w, line)
+		if err != nil {
+			external.Errorw("invalid graphite line",
+				"line", string(line),
+				"peer", addr.String(),
+				"err", err.Error(),
+			)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs"
+)
+
+//
+// a restricted unpickler that only understands the opcodes required to load
+// lists and tuples of strings and numbers, which is all that carbon sends over
+// the pickle protocol. anything that could construct arbitrary objects, like
+// GLOBAL or REDUCE, is rejected.
+//
+
+// pickle opcodes that we understand.
+const (
+	opMark           = '('
+	opStop           = '.'
+	opInt            = 'I'
+	opBinInt         = 'J'
+	opBinInt1        = 'K'
+	opBinInt2        = 'M'
+	opLong           = 'L'
+	opLong1          = 0x8a
+	opLong4          = 0x8b
+	opFloat          = 'F'
+	opBinFloat       = 'G'
+	opString         = 'S'
+	opBinString      = 'T'
+	opShortBinString = 'U'
+	opUnicode        = 'V'
+	opBinUnicode     = 'X'
+	opShortBinUni    = 0x8c
+	opBinUnicode8    = 0x8d
+	opBinBytes       = 'B'
+	opShortBinBytes  = 'C'
+	opEmptyList      = ']'
+	opList           = 'l'
+	opAppend         = 'a'
+	opAppends        = 'e'
+	opEmptyTuple     = ')'
+	opTuple          = 't'
+	opTuple1         = 0x85
+	opTuple2         = 0x86
+	opTuple3         = 0x87
+	opPut            = 'p'
+	opBinPut         = 'q'
+	opLongBinPut     = 'r'
+	opGet            = 'g'
+	opBinGet         = 'h'
+	opLongBinGet     = 'j'
+	opMemoize        = 0x94
+	opProto          = 0x80
+	opFrame          = 0x95
+)
+
+// pickleList is a list loaded from a pickle. it is a pointer so that appends
+// are visible to any memoized references.
+type pickleList struct {
+	items []interface{}
+}
+
+// pickleTuple is a tuple loaded from a pickle.
+type pickleTuple []interface{}
+
+// unpickler keeps the state required to load a pickle.
+type unpickler struct {
+	data  []byte
+	stack []interface{}
+	marks []int
+	memo  map[int]interface{}
+}
+
+// unpickle loads the value out of the pickled data. Strings are returned as
+// string, numbers as float64, lists as *pickleList and tuples as pickleTuple.
+func unpickle(data []byte) (interface{}, error) {
+	u := unpickler{
+		data: data,
+		memo: make(map[int]interface{}),
+	}
+	return u.load()
+}
+
+// load runs the opcodes until a stop.
+func (u *unpickler) load() (interface{}, error) {
+	for len(u.data) > 0 {
+		op := u.data[0]
+		u.data = u.data[1:]
+
+		var err error
+		switch op {
+		case opStop:
+			if len(u.stack) != 1 {
+				return nil, errs.New("invalid stack size at stop: %d",
+					len(u.stack))
+			}
+			return u.stack[0], nil
+
+		case opProto:
+			_, err = u.next(1)
+
+		case opFrame:
+			_, err = u.next(8)
+
+		case opMark:
+			u.marks = append(u.marks, len(u.stack))
+
+		case opInt, opLong:
+			err = u.loadIntLine()
+
+		case opBinInt:
+			var buf []byte
+			if buf, err = u.next(4); err == nil {
+				u.push(float64(int32(binary.LittleEndian.Uint32(buf))))
+			}
+
+		case opBinInt1:
+			var buf []byte
+			if buf, err = u.next(1); err == nil {
+				u.push(float64(buf[0]))
+			}
+
+		case opBinInt2:
+			var buf []byte
+			if buf, err = u.next(2); err == nil {
+				u.push(float64(binary.LittleEndian.Uint16(buf)))
+			}
+
+		case opLong1:
+			err = u.loadLong(1)
+
+		case opLong4:
+			err = u.loadLong(4)
+
+		case opFloat:
+			var line []byte
+			if line, err = u.line(); err == nil {
+				var val float64
+				val, err = strconv.ParseFloat(string(line), 64)
+				u.push(val)
+			}
+
+		case opBinFloat:
+			var buf []byte
+			if buf, err = u.next(8); err == nil {
+				u.push(math.Float64frombits(binary.BigEndian.Uint64(buf)))
+			}
+
+		case opString:
+			err = u.loadQuotedString()
+
+		case opUnicode:
+			err = u.loadRawUnicode()
+
+		case opShortBinString, opShortBinUni, opShortBinBytes:
+			err = u.loadSized(1)
+
+		case opBinString, opBinUnicode, opBinBytes:
+			err = u.loadSized(4)
+
+		case opBinUnicode8:
+			err = u.loadSized(8)
+
+		case opEmptyList:
+			u.push(&pickleList{})
+
+		case opList:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(&pickleList{items: items})
+			}
+
+		case opAppend:
+			err = u.appendItems(1)
+
+		case opAppends:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.stack = append(u.stack, items...)
+				err = u.appendItems(len(items))
+			}
+
+		case opEmptyTuple:
+			u.push(pickleTuple{})
+
+		case opTuple:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(pickleTuple(items))
+			}
+
+		case opTuple1, opTuple2, opTuple3:
+			err = u.loadTuple(int(op-opTuple1) + 1)
+
+		case opPut:
+			var index int
+			if index, err = u.lineIndex(); err == nil {
+				err = u.put(index)
+			}
+
+		case opBinPut:
+			var buf []byte
+			if buf, err = u.next(1); err == nil {
+				err = u.put(int(buf[0]))
+			}
+
+		case opLongBinPut:
+			var buf []byte
+			if buf, err = u.next(4); err == nil {
+				err = u.put(int(binary.LittleEndian.Uint32(buf)))
+			}
+
+		case opMemoize:
+			err = u.put(len(u.memo))
+
+		case opGet:
+			var index int
+			if index, err = u.lineIndex(); err == nil {
+				err = u.get(index)
+			}
+
+		case opBinGet:
+			var buf []byte
+			if buf, err = u.next(1); err == nil {
+				err = u.get(int(buf[0]))
+			}
+
+		case opLongBinGet:
+			var buf []byte
+			if buf, err = u.next(4); err == nil {
+				err = u.get(int(binary.LittleEndian.Uint32(buf)))
+			}
+
+		default:
+			return nil, errs.New("unsupported pickle opcode: %#x", op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errs.New("pickle missing stop opcode")
+}
+
+// next returns the next n bytes of data.
+func (u *unpickler) next(n int) ([]byte, error) {
+	if n < 0 || len(u.data) < n {
+		return nil, errs.New("pickle truncated")
+	}
+	out := u.data[:n]
+	u.data = u.data[n:]
+	return out, nil
+}
+
+// line returns the data up to the next newline, consuming the newline.
+func (u *unpickler) line() ([]byte, error) {
+	index := bytes.IndexByte(u.data, '\n')
+	if index == -1 {
+		return nil, errs.New("pickle truncated")
+	}
+	out := u.data[:index]
+	u.data = u.data[index+1:]
+	return out, nil
+}
+
+// lineIndex reads a memo index from a line.
+func (u *unpickler) lineIndex() (int, error) {
+	line, err := u.line()
+	if err != nil {
+		return 0, err
+	}
+	index, err := strconv.ParseInt(string(line), 10, 0)
+	if err != nil {
+		return 0, errs.Wrap(err)
+	}
+	return int(index), nil
+}
+
+// push pushes the value on to the stack.
+func (u *unpickler) push(val interface{}) {
+	u.stack = append(u.stack, val)
+}
+
+// popMark removes all of the values on the stack up to the last mark.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	if len(u.marks) == 0 {
+		return nil, errs.New("pickle missing mark")
+	}
+	mark := u.marks[len(u.marks)-1]
+	u.marks = u.marks[:len(u.marks)-1]
+	if mark > len(u.stack) {
+		return nil, errs.New("pickle stack underflow")
+	}
+
+	items := append([]interface{}(nil), u.stack[mark:]...)
+	u.stack = u.stack[:mark]
+	return items, nil
+}
+
+// appendItems appends the top n values of the stack to the list below them.
+func (u *unpickler) appendItems(n int) error {
+	if len(u.stack) < n+1 {
+		return errs.New("pickle stack underflow")
+	}
+	list, ok := u.stack[len(u.stack)-n-1].(*pickleList)
+	if !ok {
+		return errs.New("append to non-list")
+	}
+	list.items = append(list.items, u.stack[len(u.stack)-n:]...)
+	u.stack = u.stack[:len(u.stack)-n]
+	return nil
+}
+
+// loadTuple builds a tuple out of the top n values of the stack.
+func (u *unpickler) loadTuple(n int) error {
+	if len(u.stack) < n {
+		return errs.New("pickle stack underflow")
+	}
+	items := append(pickleTuple(nil), u.stack[len(u.stack)-n:]...)
+	u.stack = u.stack[:len(u.stack)-n]
+	u.push(items)
+	return nil
+}
+
+// put stores the top of the stack in the memo.
+func (u *unpickler) put(index int) error {
+	if len(u.stack) == 0 {
+		return errs.New("pickle stack underflow")
+	}
+	u.memo[index] = u.stack[len(u.stack)-1]
+	return nil
+}
+
+// get pushes the value from the memo on to the stack.
+func (u *unpickler) get(index int) error {
+	val, ok := u.memo[index]
+	if !ok {
+		return errs.New("pickle missing memo: %d", index)
+	}
+	u.push(val)
+	return nil
+}
+
+// loadIntLine loads an integer from a line, handling the boolean forms and
+// any trailing long marker.
+func (u *unpickler) loadIntLine() error {
+	line, err := u.line()
+	if err != nil {
+		return err
+	}
+	line = bytes.TrimSuffix(line, []byte("L"))
+
+	switch string(line) {
+	case "00":
+		u.push(0.0)
+		return nil
+	case "01":
+		u.push(1.0)
+		return nil
+	}
+
+	val, ok := new(big.Int).SetString(string(line), 10)
+	if !ok {
+		return errs.New("invalid integer: %q", line)
+	}
+	fval, _ := new(big.Float).SetInt(val).Float64()
+	u.push(fval)
+	return nil
+}
+
+// loadLong loads a little endian two's complement integer with a length
+// prefix of size bytes.
+func (u *unpickler) loadLong(size int) error {
+	n, err := u.size(size)
+	if err != nil {
+		return err
+	}
+	buf, err := u.next(n)
+	if err != nil {
+		return err
+	}
+
+	// reverse into big endian for big.Int
+	be := make([]byte, len(buf))
+	for i := range buf {
+		be[len(buf)-1-i] = buf[i]
+	}
+	val := new(big.Int).SetBytes(be)
+	if len(be) > 0 && be[0]&0x80 != 0 {
+		val.Sub(val, new(big.Int).Lsh(big.NewInt(1), uint(8*len(be))))
+	}
+
+	fval, _ := new(big.Float).SetInt(val).Float64()
+	u.push(fval)
+	return nil
+}
+
+// size reads a little endian length prefix of the given number of bytes.
+func (u *unpickler) size(size int) (int, error) {
+	buf, err := u.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for i := len(buf) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(buf[i])
+	}
+	if n > uint64(len(u.data)) {
+		return 0, errs.New("pickle truncated")
+	}
+	return int(n), nil
+}
+
+// loadSized loads a string with a length prefix of size bytes.
+func (u *unpickler) loadSized(size int) error {
+	n, err := u.size(size)
+	if err != nil {
+		return err
+	}
+	buf, err := u.next(n)
+	if err != nil {
+		return err
+	}
+	u.push(string(buf))
+	return nil
+}
+
+// loadQuotedString loads a python repr style string from a line.
+func (u *unpickler) loadQuotedString() error {
+	line, err := u.line()
+	if err != nil {
+		return err
+	}
+	if len(line) < 2 || line[0] != line[len(line)-1] ||
+		(line[0] != '\'' && line[0] != '"') {
+
+		return errs.New("invalid string: %q", line)
+	}
+	line = line[1 : len(line)-1]
+
+	out := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			out = append(out, line[i])
+			continue
+		}
+		i++
+		if i >= len(line) {
+			return errs.New("invalid string escape")
+		}
+		switch ch := line[i]; ch {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'x':
+			if i+2 >= len(line) {
+				return errs.New("invalid string escape")
+			}
+			val, err := strconv.ParseUint(string(line[i+1:i+3]), 16, 8)
+			if err != nil {
+				return errs.Wrap(err)
+			}
+			out = append(out, byte(val))
+			i += 2
+		default:
+			out = append(out, ch)
+		}
+	}
+
+	u.push(string(out))
+	return nil
+}
+
+// loadRawUnicode loads a raw-unicode-escape encoded string from a line.
+func (u *unpickler) loadRawUnicode() error {
+	line, err := u.line()
+	if err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		width := 0
+		if line[i] == '\\' && i+1 < len(line) {
+			switch line[i+1] {
+			case 'u':
+				width = 4
+			case 'U':
+				width = 8
+			}
+		}
+		if width == 0 || i+2+width > len(line) {
+			// unescaped bytes are latin-1 code points.
+			var buf [utf8.UTFMax]byte
+			out = append(out, buf[:utf8.EncodeRune(buf[:], rune(line[i]))]...)
+			continue
+		}
+
+		val, err := strconv.ParseUint(string(line[i+2:i+2+width]), 16, 32)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		var buf [utf8.UTFMax]byte
+		out = append(out, buf[:utf8.EncodeRune(buf[:], rune(val))]...)
+		i += 1 + width
+	}
+
+	u.push(string(out))
+	return nil
+}
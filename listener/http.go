@@ -0,0 +1,48 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+// RunHTTP serves the handler on the address until the context is canceled.
+// It is a helper for listeners that receive data over http.
+func RunHTTP(ctx context.Context, address string, handler http.Handler) (
+	err error) {
+
+	// we make the listener ourselves so that it can be closed if the context
+	// is canceled before Serve is called, like the api server.
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	defer lis.Close()
+
+	srv := &http.Server{Handler: handler}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(), 10*time.Second)
+		defer cancel()
+
+		srv.Shutdown(ctx)
+		lis.Close()
+	}()
+
+	err = srv.Serve(lis)
+	if err == http.ErrServerClosed || ctx.Err() != nil {
+		err = nil
+	}
+	return errs.Wrap(err)
+}
@@ -0,0 +1,4 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package influx provides a listener for the InfluxDB line protocol.
+package influx
@@ -0,0 +1,247 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// maxLine is the longest line that will be accepted.
+const maxLine = 1 << 20
+
+// Options controls the behavior of the influx listener.
+type Options struct {
+	// Protocol is the network protocol to listen with, either "http", "tcp"
+	// or "udp". Defaults to "http".
+	Protocol string
+
+	// Precision is the precision of the timestamps, one of "ns", "us", "ms",
+	// "s", "m" or "h". Over http, the precision query parameter overrides
+	// it. Defaults to "ns".
+	Precision string
+
+	// Bufsize is the size of the buffer used to read udp datagrams. Defaults
+	// to 65535.
+	Bufsize int
+
+	// Late controls what happens to values that are late. Defaults to
+	// listener.LateAccept, which ignores timestamps.
+	Late listener.LatePolicy
+
+	// Tolerance is how far before the start of the current set of records,
+	// or after the current time, a timestamp is allowed to be without being
+	// considered late.
+	Tolerance time.Duration
+
+	// LateSuffix is appended to the metric name for late values when the
+	// policy is listener.LateSeparate. Defaults to ".late".
+	LateSuffix string
+}
+
+// Listener implements the listener.Listener for the InfluxDB line protocol.
+// Every numeric field is added to a metric named after the measurement and
+// field key, like "measurement.field;tag=value".
+type Listener struct {
+	address   string
+	opts      Options
+	precision time.Duration
+	late      *listener.Late
+}
+
+// New returns a Listener that when Run will listen on the provided address.
+func New(address string, opts Options) (*Listener, error) {
+	if opts.Protocol == "" {
+		opts.Protocol = "http"
+	}
+	if opts.Bufsize == 0 {
+		opts.Bufsize = 65535
+	}
+
+	precision, err := parsePrecision(opts.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listener{
+		address:   address,
+		opts:      opts,
+		precision: precision,
+		late: &listener.Late{
+			Policy:    opts.Late,
+			Tolerance: opts.Tolerance,
+			Suffix:    opts.LateSuffix,
+			Name:      "influx",
+		},
+	}, nil
+}
+
+// Dropped returns how many late values have been dropped.
+func (l *Listener) Dropped() int64 {
+	return l.late.Dropped()
+}
+
+// Run listens on the address and writes all of the metrics to the writer.
+func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
+	switch l.opts.Protocol {
+	case "http":
+		return listener.RunHTTP(ctx, l.address, l.handler(ctx, w))
+	case "tcp":
+		return listener.RunTCP(ctx, l.address,
+			func(ctx context.Context, conn net.Conn) error {
+				return l.handleConn(ctx, w, conn)
+			})
+	case "udp":
+		return listener.RunUDP(ctx, l.address, l.opts.Bufsize,
+			func(packet []byte, addr net.Addr) {
+				l.handlePacket(ctx, w, packet, addr)
+			})
+	default:
+		return errs.New("unknown protocol: %q", l.opts.Protocol)
+	}
+}
+
+// handler returns an http.Handler that serves the influx /write endpoints.
+func (l *Listener) handler(ctx context.Context, w *data.Writer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	write := func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := l.handleRequest(ctx, w, req)
+		if err != nil {
+			external.Errorw("invalid influx write",
+				"peer", req.RemoteAddr,
+				"err", err.Error(),
+			)
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}
+	mux.HandleFunc("/write", write)
+	mux.HandleFunc("/api/v2/write", write)
+
+	return mux
+}
+
+// handleRequest adds all of the lines in the body of the request to the
+// writer. Every valid line is added, and the first error is returned.
+func (l *Listener) handleRequest(ctx context.Context, w *data.Writer,
+	req *http.Request) (err error) {
+
+	precision := l.precision
+	if raw := req.URL.Query().Get("precision"); raw != "" {
+		precision, err = parsePrecision(raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var first error
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxLine)
+	for scanner.Scan() {
+		err := l.handleLine(ctx, w, scanner.Bytes(), precision)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errs.Wrap(err)
+	}
+	return first
+}
+
+// handleConn handles lines from the connection and adds them to the writer.
+func (l *Listener) handleConn(ctx context.Context, w *data.Writer,
+	conn net.Conn) (err error) {
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxLine)
+	for scanner.Scan() {
+		err := l.handleLine(ctx, w, scanner.Bytes(), l.precision)
+		if err != nil {
+			external.Errorw("invalid influx line",
+				"line", scanner.Text(),
+				"peer", conn.RemoteAddr().String(),
+				"err", err.Error(),
+			)
+		}
+	}
+	return scanner.Err()
+}
+
+// handlePacket adds every line in the datagram to the writer.
+func (l *Listener) handlePacket(ctx context.Context, w *data.Writer,
+	packet []byte, addr net.Addr) {
+
+	for len(packet) > 0 {
+		var line []byte
+		if index := bytes.IndexByte(packet, '\n'); index >= 0 {
+			line, packet = packet[:index], packet[index+1:]
+		} else {
+			line, packet = packet, nil
+		}
+
+		err := l.handleLine(ctx, w, line, l.precision)
+		if err != nil {
+			external.Errorw("invalid influx line",
+				"line", string(line),
+				"peer", addr.String(),
+				"err", err.Error(),
+			)
+		}
+	}
+}
+
+// handleLine adds the numeric fields in the line to the writer. Blank lines
+// and comments are ignored.
+func (l *Listener) handleLine(ctx context.Context, w *data.Writer,
+	line []byte, precision time.Duration) (err error) {
+
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' {
+		return nil
+	}
+
+	p, err := parseLine(string(line))
+	if err != nil {
+		return err
+	}
+
+	at := p.time(precision)
+	for _, f := range p.fields {
+		metric := listener.MetricName(p.measurement+"."+f.key, p.tags)
+		l.late.Add(ctx, w, metric, at, f.value, nil)
+	}
+	return nil
+}
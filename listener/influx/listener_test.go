@@ -0,0 +1,122 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package influx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/assert"
+	"github.com/vivint/rothko/listener"
+)
+
+func TestListenerHTTP(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l, err := New("", Options{Late: listener.LateDrop, Tolerance: time.Minute})
+	assert.NoError(t, err)
+	h := l.handler(ctx, w)
+
+	write := func(query, body string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/write"+query,
+			strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// the timestamps are the current time in nanoseconds, the distant
+	// future in nanoseconds, and the distant past in seconds.
+	now := time.Now().Add(time.Second).UnixNano()
+	assert.Equal(t, write("", "cpu,host=b,az=1 user=1,sys=2i\n"+
+		"# a comment\n\n"+
+		fmt.Sprintf("cpu,host=b,az=1 user=3 %d\n", now)+
+		"cpu,host=b,az=1 user=7 4000000000000000000\n"),
+		http.StatusNoContent)
+	assert.Equal(t, write("?precision=s", "cpu,host=b,az=1 user=5 4000"),
+		http.StatusNoContent)
+	assert.Equal(t, write("?precision=x", "cpu user=5"),
+		http.StatusBadRequest)
+	assert.Equal(t, write("", "cpu user=6\nbad\n"), http.StatusBadRequest)
+
+	assert.Equal(t, l.Dropped(), int64(2))
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"cpu.user;az=1;host=b": {2, 1, 3},
+		"cpu.sys;az=1;host=b":  {1, 2, 2},
+		"cpu.user":             {1, 6, 6},
+	})
+}
+
+func TestListenerUDP(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l, err := New("", Options{Protocol: "udp"})
+	assert.NoError(t, err)
+
+	l.handlePacket(ctx, w, []byte("mem free=1\nmem free=3\r\nbad"),
+		&net.UDPAddr{})
+
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"mem.free": {2, 1, 3},
+	})
+}
+
+func TestListenerTCP(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l, err := New("", Options{Protocol: "tcp"})
+	assert.NoError(t, err)
+
+	client, server := net.Pipe()
+	go func() {
+		client.Write([]byte("disk,dev=sda used=7\n"))
+		client.Close()
+	}()
+	assert.NoError(t, l.handleConn(ctx, w, server))
+
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"disk.used;dev=sda": {1, 7, 7},
+	})
+}
+
+//
+// helpers
+//
+
+type result struct {
+	obs      int64
+	min, max float64
+}
+
+func capture(ctx context.Context, w *data.Writer) map[string]result {
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{rec.Observations, rec.Min, rec.Max}
+			return true
+		})
+	return got
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeParams struct{ dist.Params }
+
+func (fakeParams) Kind() string            { return "fake" }
+func (fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
+
+type fakeDist struct{ dist.Dist }
+
+func (fakeDist) Kind() string            { return "fake" }
+func (fakeDist) Observe(val float64)     {}
+func (fakeDist) Marshal(x []byte) []byte { return x }
@@ -0,0 +1,196 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package influx
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// point is a parsed line of the line protocol. Only the numeric fields are
+// kept.
+type point struct {
+	measurement string
+	tags        []listener.Tag
+	fields      []field
+	timestamp   int64
+	stamped     bool
+}
+
+// field is a numeric field of a point.
+type field struct {
+	key   string
+	value float64
+}
+
+// precisions maps the names of the timestamp precisions to their durations.
+var precisions = map[string]time.Duration{
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// parsePrecision returns the duration for the precision, where the empty
+// string is nanoseconds.
+func parsePrecision(precision string) (time.Duration, error) {
+	if precision == "" {
+		return time.Nanosecond, nil
+	}
+	unit, ok := precisions[precision]
+	if !ok {
+		return 0, errs.New("unknown precision: %q", precision)
+	}
+	return unit, nil
+}
+
+// time returns the time of the point using the precision, or the zero time
+// if the point has no timestamp.
+func (p point) time(precision time.Duration) time.Time {
+	if !p.stamped {
+		return time.Time{}
+	}
+	return time.Unix(0, p.timestamp*int64(precision))
+}
+
+// parseLine parses a line like `measurement,tag=v field=1.2,field2=3i ts`.
+func parseLine(line string) (p point, err error) {
+	sections := split(line, ' ', true)
+	if len(sections) != 2 && len(sections) != 3 {
+		return point{}, errs.New("bad number of sections: %d", len(sections))
+	}
+
+	series := split(sections[0], ',', false)
+	p.measurement = unescape(series[0])
+	if p.measurement == "" {
+		return point{}, errs.New("missing measurement")
+	}
+	for _, tag := range series[1:] {
+		kv := split(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" {
+			return point{}, errs.New("invalid tag: %q", tag)
+		}
+		p.tags = append(p.tags, listener.Tag{
+			Key:   unescape(kv[0]),
+			Value: unescape(kv[1]),
+		})
+	}
+
+	for _, raw := range split(sections[1], ',', true) {
+		index := indexUnescaped(raw, '=')
+		if index <= 0 || index == len(raw)-1 {
+			return point{}, errs.New("invalid field: %q", raw)
+		}
+
+		value, numeric, err := parseValue(raw[index+1:])
+		if err != nil {
+			return point{}, err
+		}
+		if numeric {
+			p.fields = append(p.fields, field{
+				key:   unescape(raw[:index]),
+				value: value,
+			})
+		}
+	}
+
+	if len(sections) == 3 {
+		p.timestamp, err = strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return point{}, errs.Wrap(err)
+		}
+		p.stamped = true
+	}
+
+	return p, nil
+}
+
+// parseValue parses a field value, returning false if it is a string or a
+// boolean.
+func parseValue(raw string) (value float64, numeric bool, err error) {
+	switch raw {
+	case "t", "T", "true", "True", "TRUE", "f", "F", "false", "False", "FALSE":
+		return 0, false, nil
+	}
+	if raw[0] == '"' {
+		if len(raw) < 2 || raw[len(raw)-1] != '"' {
+			return 0, false, errs.New("unterminated string: %q", raw)
+		}
+		return 0, false, nil
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		x, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		return float64(x), true, errs.Wrap(err)
+	case 'u':
+		x, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		return float64(x), true, errs.Wrap(err)
+	}
+
+	value, err = strconv.ParseFloat(raw, 64)
+	return value, true, errs.Wrap(err)
+}
+
+// split splits the string on the separator when it is not escaped with a
+// backslash, and if quotes is true, when it is not inside of a double quoted
+// string. Empty parts from repeated spaces are removed.
+func split(s string, sep byte, quotes bool) (parts []string) {
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\\':
+			i++
+		case ch == '"' && quotes:
+			quoted = !quoted
+		case ch == sep && !quoted:
+			if sep != ' ' || i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if sep != ' ' || len(s) > start {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// indexUnescaped returns the index of the first unescaped byte in the
+// string, or -1.
+func indexUnescaped(s string, b byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case b:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescaper removes the backslashes in front of the characters that can be
+// escaped in measurements, tags and field keys.
+var unescaper = strings.NewReplacer(
+	`\,`, `,`,
+	`\=`, `=`,
+	`\ `, ` `,
+	`\\`, `\`,
+)
+
+// unescape removes the escaping from the string.
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') == -1 {
+		return s
+	}
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/internal/assert"
+	"github.com/vivint/rothko/listener"
+)
+
+func TestParseLine(t *testing.T) {
+	p, err := parseLine(`cpu\ load,host=a\,b,region=us\ west ` +
+		`value=1.5,count=3i,big=4u,ok=true,msg="a \"b\", c=d" 1500000000`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, p, point{
+		measurement: "cpu load",
+		tags: []listener.Tag{
+			{Key: "host", Value: "a,b"},
+			{Key: "region", Value: "us west"},
+		},
+		fields: []field{
+			{key: "value", value: 1.5},
+			{key: "count", value: 3},
+			{key: "big", value: 4},
+		},
+		timestamp: 1500000000,
+		stamped:   true,
+	})
+	assert.Equal(t, p.time(time.Second), time.Unix(1500000000, 0))
+
+	p, err = parseLine("mem free=10")
+	assert.NoError(t, err)
+	assert.That(t, p.time(time.Second).IsZero())
+
+	for _, bad := range []string{
+		"mem",
+		",host=a value=1",
+		"mem,host value=1",
+		"mem value",
+		"mem value=x",
+		"mem value=1 x",
+		`mem value="open`,
+		"mem value=1 1 1",
+	} {
+		_, err := parseLine(bad)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package influx
+
+import (
+	"context"
+	"time"
+
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/listener"
+	"github.com/vivint/rothko/registry"
+	"github.com/zeebo/errs"
+)
+
+func init() {
+	registry.RegisterListener("influx", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			address := a.I("address").String()
+			protocol := a.I("protocol").String()
+			precision := a.I("precision").String()
+			bufsize := a.I("bufsize").Int64()
+			late := a.I("late").String()
+			tolerance := a.I("tolerance").String()
+			late_suffix := a.I("late_suffix").String()
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			opts := Options{
+				Protocol:   protocol,
+				Precision:  precision,
+				Bufsize:    int(bufsize),
+				LateSuffix: late_suffix,
+			}
+			switch opts.Protocol {
+			case "", "http", "tcp", "udp":
+			default:
+				return nil, errs.New("unknown protocol: %q", protocol)
+			}
+
+			var err error
+			opts.Late, err = listener.ParseLatePolicy(late)
+			if err != nil {
+				return nil, err
+			}
+			if tolerance != "" {
+				opts.Tolerance, err = time.ParseDuration(tolerance)
+				if err != nil {
+					return nil, errs.Wrap(err)
+				}
+			}
+
+			return New(address, opts)
+		}))
+}
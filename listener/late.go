@@ -0,0 +1,115 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/zeebo/errs"
+)
+
+// LatePolicy controls what happens to values with a timestamp before the
+// start of the Writer's current set of records, or too far in the future.
+type LatePolicy string
+
+const (
+	// LateAccept ignores timestamps entirely, adding every value to the
+	// current set of records as if it happened now. No policy adds a value
+	// to the set of records its timestamp belongs in.
+	LateAccept LatePolicy = "accept"
+
+	// LateSeparate adds late values into a separate metric named by adding
+	// a suffix to the metric name.
+	LateSeparate LatePolicy = "separate"
+
+	// LateDrop discards late values, keeping count of how many were dropped.
+	LateDrop LatePolicy = "drop"
+)
+
+// ParseLatePolicy returns the LatePolicy for the string, where the empty
+// string is LateAccept.
+func ParseLatePolicy(policy string) (LatePolicy, error) {
+	switch LatePolicy(policy) {
+	case "":
+		return LateAccept, nil
+	case LateAccept, LateSeparate, LateDrop:
+		return LatePolicy(policy), nil
+	default:
+		return "", errs.New("unknown late policy: %q", policy)
+	}
+}
+
+// Late adds timestamped values to a Writer, applying a LatePolicy to the
+// values that are late.
+type Late struct {
+	// Policy controls what happens to values that are late. Defaults to
+	// LateAccept, which ignores timestamps.
+	Policy LatePolicy
+
+	// Tolerance is how far before the start of the current set of records,
+	// or after the current time, a timestamp is allowed to be without being
+	// considered late.
+	Tolerance time.Duration
+
+	// Suffix is appended to the metric name, before any graphite tags, for
+	// late values when the policy is LateSeparate. Defaults to ".late".
+	Suffix string
+
+	// Name is the prefix of the external metric counting dropped values,
+	// like "name_late_dropped".
+	Name string
+
+	dropped int64 // atomic
+}
+
+// Dropped returns how many late values have been dropped.
+func (l *Late) Dropped() int64 {
+	return atomic.LoadInt64(&l.dropped)
+}
+
+// Add adds the value and id to the writer, applying the late policy based on
+// the time. A zero time is treated as the current time. Times further in the
+// future than the tolerance are treated as late, since they can not be
+// trusted to be in the current set of records either. It returns DropLate
+// for late values, whatever the policy did with them, and otherwise why the
+// writer dropped the value, if it did.
+func (l *Late) Add(ctx context.Context, w *data.Writer, metric string,
+	at time.Time, value float64, id []byte) data.Drop {
+
+	if l.Policy == "" || l.Policy == LateAccept || at.IsZero() {
+		return w.AddAt(ctx, metric, time.Time{}, value, id)
+	}
+
+	// values dropped because they are invalid or beyond the limits are not
+	// late, so the policy does not apply to them.
+	if !at.After(time.Now().Add(l.Tolerance)) {
+		drop := w.AddAt(ctx, metric, at.Add(l.Tolerance), value, id)
+		if drop != data.DropLate {
+			return drop
+		}
+	}
+
+	switch l.Policy {
+	case LateSeparate:
+		suffix := l.Suffix
+		if suffix == "" {
+			suffix = ".late"
+		}
+
+		name, tags := metric, ""
+		if index := strings.IndexByte(metric, ';'); index >= 0 {
+			name, tags = metric[:index], metric[index:]
+		}
+		w.Add(ctx, name+suffix+tags, value, id)
+
+	case LateDrop:
+		atomic.AddInt64(&l.dropped, 1)
+		external.Observe(l.Name+"_late_dropped", 1)
+	}
+	return data.DropLate
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs"
+)
+
+// Tag is a key and value to be included in a metric name.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// tagReplacer replaces the characters that have meaning in a graphite tagged
+// metric name.
+var tagReplacer = strings.NewReplacer(";", "_", "=", "_")
+
+// MetricName returns the name with the tags sorted by their key in the
+// graphite tagged form, like "name;a=b;c=d". Tags with an empty key or value
+// are skipped, and if a key is included more than once, the last value is
+// used. Semicolons and equals signs are replaced with underscores, except
+// equals signs in values.
+func MetricName(name string, tags []Tag) string {
+	sorted := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Key == "" || tag.Value == "" {
+			continue
+		}
+		sorted = append(sorted, tag)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	var buf strings.Builder
+	buf.WriteString(tagReplacer.Replace(name))
+	for i, tag := range sorted {
+		if i+1 < len(sorted) && sorted[i+1].Key == tag.Key {
+			continue
+		}
+		buf.WriteByte(';')
+		buf.WriteString(tagReplacer.Replace(tag.Key))
+		buf.WriteByte('=')
+		buf.WriteString(strings.Replace(tag.Value, ";", "_", -1))
+	}
+	return buf.String()
+}
+
+// CanonicalName parses the graphite tags out of the metric and returns the
+// metric with the tags sorted by their key, like "name;a=b;c=d". If a tag is
+// specified more than once, the last value is used.
+func CanonicalName(metric string) (string, error) {
+	index := strings.IndexByte(metric, ';')
+	if index == -1 {
+		return metric, nil
+	}
+	name, tags := metric[:index], metric[index:]
+	if name == "" {
+		return "", errs.New("empty metric name")
+	}
+
+	parts := strings.Split(tags[1:], ";")
+	values := make(map[string]string, len(parts))
+	keys := make([]string, 0, len(parts))
+	for _, part := range parts {
+		index := strings.IndexByte(part, '=')
+		if index <= 0 || index == len(part)-1 {
+			return "", errs.New("invalid tag: %q", part)
+		}
+
+		key, value := part[:index], part[index+1:]
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	buf.Grow(len(metric))
+	buf.WriteString(name)
+	for _, key := range keys {
+		buf.WriteByte(';')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(values[key])
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"testing"
+
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestMetricName(t *testing.T) {
+	assert.Equal(t, MetricName("foo", nil), "foo")
+	assert.Equal(t, MetricName("foo;bar", []Tag{
+		{"z", "1"},
+		{"a", "x;y"},
+		{"empty", ""},
+		{"", "empty"},
+		{"k=v", "a=b"},
+		{"z", "2"},
+	}), "foo_bar;a=x_y;k_v=a=b;z=2")
+}
+
+func TestCanonicalName(t *testing.T) {
+	f := func(metric string) string {
+		t.Helper()
+		name, err := CanonicalName(metric)
+		assert.NoError(t, err)
+		return name
+	}
+
+	assert.Equal(t, f("cpu.load"), "cpu.load")
+	assert.Equal(t, f("cpu.load;host=web1"), "cpu.load;host=web1")
+	assert.Equal(t, f("cpu.load;host=web1;dc=east"), "cpu.load;dc=east;host=web1")
+	assert.Equal(t, f("cpu.load;a=1;b=2;a=3"), "cpu.load;a=3;b=2")
+
+	for _, bad := range []string{";a=b", "cpu;a", "cpu;=b", "cpu;a=", "cpu;"} {
+		_, err := CanonicalName(bad)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/listener"
+)
+
+// addRequest adds all of the metrics in the export request to the writer.
+// Gauges and non-monotonic sums are added directly, monotonic sums are added
+// as their rate per second, histograms are expanded into observations of
+// their buckets, and summaries add their quantiles with a quantile tag.
+func (l *Listener) addRequest(ctx context.Context, w *data.Writer,
+	er exportRequest) {
+
+	for _, rm := range er.ResourceMetrics {
+		var res_tags []listener.Tag
+		for _, key := range l.opts.ResourceAttributes {
+			for _, kv := range rm.Resource.Attributes {
+				if kv.Key == key {
+					res_tags = append(res_tags, listener.Tag{
+						Key:   kv.Key,
+						Value: kv.Value.String(),
+					})
+				}
+			}
+		}
+
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				l.addMetric(ctx, w, m, res_tags, rm.Resource.Attributes)
+			}
+		}
+	}
+}
+
+// addMetric adds the data points of the metric to the writer. The cumulative
+// state of a data point is tracked by the series key built from every
+// resource attribute, rather than the metric name that may only include some
+// of them.
+func (l *Listener) addMetric(ctx context.Context, w *data.Writer, m metric,
+	res_tags []listener.Tag, res_attrs []keyValue) {
+
+	name := func(attrs []keyValue, extra ...listener.Tag) string {
+		tags := append([]listener.Tag(nil), res_tags...)
+		for _, kv := range attrs {
+			tags = append(tags, listener.Tag{
+				Key:   kv.Key,
+				Value: kv.Value.String(),
+			})
+		}
+		return listener.MetricName(m.Name, append(tags, extra...))
+	}
+
+	switch {
+	case m.Gauge != nil:
+		for _, p := range m.Gauge.DataPoints {
+			w.Add(ctx, name(p.Attributes), numberValue(p.AsDouble, p.AsInt),
+				exemplarId(p.Exemplars))
+		}
+
+	case m.Sum != nil:
+		for _, p := range m.Sum.DataPoints {
+			metric := name(p.Attributes)
+			value := numberValue(p.AsDouble, p.AsInt)
+			id := exemplarId(p.Exemplars)
+
+			if !m.Sum.IsMonotonic {
+				w.Add(ctx, metric, value, id)
+				continue
+			}
+
+			at := time.Unix(0, int64(p.TimeUnixNano))
+			switch m.Sum.AggregationTemporality {
+			case temporalityDelta:
+				start := time.Unix(0, int64(p.StartTimeUnixNano))
+				if elapsed := at.Sub(start).Seconds(); elapsed > 0 {
+					w.Add(ctx, metric, value/elapsed, id)
+				}
+			case temporalityCumulative:
+				series := seriesKey(m.Name, res_attrs, p.Attributes,
+					p.StartTimeUnixNano)
+				if rate, ok := l.tracker.Rate(series, value, at); ok {
+					w.Add(ctx, metric, rate, id)
+				}
+			}
+		}
+
+	case m.Histogram != nil:
+		for _, p := range m.Histogram.DataPoints {
+			l.addBuckets(ctx, w, name(p.Attributes),
+				seriesKey(m.Name, res_attrs, p.Attributes,
+					p.StartTimeUnixNano),
+				m.Histogram.AggregationTemporality,
+				explicitBuckets(p), p.Exemplars)
+		}
+
+	case m.ExponentialHistogram != nil:
+		for _, p := range m.ExponentialHistogram.DataPoints {
+			l.addBuckets(ctx, w, name(p.Attributes),
+				seriesKey(m.Name, res_attrs, p.Attributes,
+					p.StartTimeUnixNano),
+				m.ExponentialHistogram.AggregationTemporality,
+				exponentialBuckets(p), p.Exemplars)
+		}
+
+	case m.Summary != nil:
+		for _, p := range m.Summary.DataPoints {
+			for _, qv := range p.QuantileValues {
+				quantile := strconv.FormatFloat(
+					float64(qv.Quantile), 'g', -1, 64)
+				w.Add(ctx, name(p.Attributes, listener.Tag{
+					Key:   "quantile",
+					Value: quantile,
+				}), float64(qv.Value), nil)
+			}
+		}
+	}
+}
+
+// seriesKey returns the key identifying the cumulative series of a data
+// point: the metric name, every resource and data point attribute in sorted
+// order, and the start time, so that a restarted process begins a new series.
+func seriesKey(name string, res_attrs, attrs []keyValue,
+	start jsonUint) string {
+
+	sorted := func(kvs []keyValue) []string {
+		out := make([]string, 0, len(kvs))
+		for _, kv := range kvs {
+			out = append(out, kv.Key+"\x00"+kv.Value.String())
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, kv := range sorted(res_attrs) {
+		b.WriteString("\x01")
+		b.WriteString(kv)
+	}
+	b.WriteString("\x02")
+	for _, kv := range sorted(attrs) {
+		b.WriteString("\x01")
+		b.WriteString(kv)
+	}
+	b.WriteString("\x02")
+	b.WriteString(strconv.FormatUint(uint64(start), 10))
+	return b.String()
+}
+
+// bucket is a histogram bucket holding the values in (lower, upper] that are
+// observed as value.
+type bucket struct {
+	key          string
+	lower, upper float64
+	value        float64
+	count        float64
+}
+
+// addBuckets observes the counts of the buckets. If the temporality is
+// cumulative, only the counts since the last time the series was seen are
+// observed. Exemplars that fall in a bucket are observed with their trace id
+// in place of one of the bucket's observations, so that the min and max can
+// link back to a trace.
+func (l *Listener) addBuckets(ctx context.Context, w *data.Writer,
+	metric, series string, temporality int, buckets []bucket,
+	exemplars []exemplar) {
+
+	if temporality == temporalityCumulative {
+		keys := make([]string, len(buckets))
+		counts := make([]float64, len(buckets))
+		for i, b := range buckets {
+			keys[i], counts[i] = b.key, b.count
+		}
+		if !l.tracker.Deltas(series, keys, counts) {
+			return
+		}
+		for i := range buckets {
+			buckets[i].count = counts[i]
+		}
+	}
+
+	used := make([]bool, len(exemplars))
+	for _, b := range buckets {
+		n := int64(math.Round(b.count))
+
+		for i, e := range exemplars {
+			value := numberValue(e.AsDouble, e.AsInt)
+			if n == 0 || used[i] || value <= b.lower || value > b.upper {
+				continue
+			}
+			w.Add(ctx, metric, value, traceId(e))
+			used[i] = true
+			n--
+		}
+
+		w.AddWeighted(ctx, metric, b.value, n, nil)
+	}
+}
+
+// explicitBuckets returns the buckets of the explicit bucket histogram data
+// point. The first and last buckets are unbounded, so the min and max of the
+// point are used to pick a value to observe for them if they are known.
+func explicitBuckets(p histogramPoint) []bucket {
+	bounds := p.ExplicitBounds
+	if len(p.BucketCounts) != len(bounds)+1 {
+		return nil
+	}
+
+	buckets := make([]bucket, 0, len(p.BucketCounts))
+	for i, count := range p.BucketCounts {
+		b := bucket{
+			key:   "+Inf",
+			lower: math.Inf(-1),
+			upper: math.Inf(1),
+			count: float64(count),
+		}
+		if i > 0 {
+			b.lower = float64(bounds[i-1])
+		}
+		if i < len(bounds) {
+			b.upper = float64(bounds[i])
+			b.key = strconv.FormatFloat(b.upper, 'g', -1, 64)
+		}
+
+		lower, upper := b.lower, b.upper
+		if i == 0 {
+			switch {
+			case p.Min != nil:
+				lower = float64(*p.Min)
+			case upper > 0:
+				lower = 0
+			default:
+				lower = upper
+			}
+		}
+		if i == len(bounds) {
+			upper = lower
+			if p.Max != nil {
+				upper = float64(*p.Max)
+			}
+		}
+		b.value = (lower + upper) / 2
+
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+// exponentialBuckets returns the buckets of the exponential histogram data
+// point. Positive bucket i holds values in (base^i, base^(i+1)] where base is
+// 2^(2^-scale), and negative buckets are mirrored.
+func exponentialBuckets(p expHistogramPoint) []bucket {
+	factor := math.Exp2(-float64(p.Scale))
+	bound := func(index int) float64 {
+		return math.Exp2(float64(index) * factor)
+	}
+
+	buckets := []bucket{{
+		key:   "0",
+		lower: -math.SmallestNonzeroFloat64,
+		count: float64(p.ZeroCount),
+	}}
+
+	for i, count := range p.Positive.BucketCounts {
+		index := int(p.Positive.Offset) + i
+		lower, upper := bound(index), bound(index+1)
+		buckets = append(buckets, bucket{
+			key:   "+" + strconv.Itoa(index),
+			lower: lower,
+			upper: upper,
+			value: (lower + upper) / 2,
+			count: float64(count),
+		})
+	}
+
+	for i, count := range p.Negative.BucketCounts {
+		index := int(p.Negative.Offset) + i
+		lower, upper := -bound(index+1), -bound(index)
+		buckets = append(buckets, bucket{
+			key:   "-" + strconv.Itoa(index),
+			lower: lower,
+			upper: upper,
+			value: (lower + upper) / 2,
+			count: float64(count),
+		})
+	}
+
+	return buckets
+}
+
+// exemplarId returns the trace id of the first exemplar that has one.
+func exemplarId(exemplars []exemplar) []byte {
+	for _, e := range exemplars {
+		if id := traceId(e); id != nil {
+			return id
+		}
+	}
+	return nil
+}
+
+// traceId returns the hex encoded trace id of the exemplar, or nil if it has
+// none.
+func traceId(e exemplar) []byte {
+	if len(e.TraceId) == 0 {
+		return nil
+	}
+	id := make([]byte, hex.EncodedLen(len(e.TraceId)))
+	hex.Encode(id, e.TraceId)
+	return id
+}
@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package otlp provides a listener for OpenTelemetry metrics sent with the
+// OTLP/HTTP protocol.
+package otlp
@@ -0,0 +1,137 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/internal/cumulative"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// maxBody is the largest decompressed body accepted.
+const maxBody = 64 << 20
+
+// Options controls the behavior of the OTLP listener.
+type Options struct {
+	// Path is the http path that accepts metrics. Defaults to "/v1/metrics".
+	Path string
+
+	// ResourceAttributes are the keys of the resource attributes that are
+	// included in the metric name, along with every data point attribute.
+	// Defaults to just "service.name".
+	ResourceAttributes []string
+}
+
+// Listener implements the listener.Listener for OTLP/HTTP metrics, encoded
+// as either protobuf or json.
+type Listener struct {
+	address string
+	opts    Options
+	tracker *cumulative.Tracker
+}
+
+// New returns a Listener that when Run will listen on the provided address.
+func New(address string, opts Options) *Listener {
+	if opts.Path == "" {
+		opts.Path = "/v1/metrics"
+	}
+	if opts.ResourceAttributes == nil {
+		opts.ResourceAttributes = []string{"service.name"}
+	}
+
+	return &Listener{
+		address: address,
+		opts:    opts,
+		tracker: cumulative.NewTracker(0),
+	}
+}
+
+// Run listens on the address and writes all of the metrics to the writer.
+func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
+	mux := http.NewServeMux()
+	mux.Handle(l.opts.Path, l.handler(ctx, w))
+	return listener.RunHTTP(ctx, l.address, mux)
+}
+
+// handler returns an http.Handler that adds the metrics from export requests
+// to the writer.
+func (l *Listener) handler(ctx context.Context, w *data.Writer) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		content_type, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		is_json := content_type == "application/json"
+
+		err := l.handleRequest(ctx, w, req, is_json)
+		if err != nil {
+			external.Errorw("invalid otlp request",
+				"peer", req.RemoteAddr,
+				"err", err.Error(),
+			)
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// respond with an empty ExportMetricsServiceResponse.
+		if is_json {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte("{}"))
+		} else {
+			rw.Header().Set("Content-Type", "application/x-protobuf")
+			rw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// handleRequest decodes the export request and adds it to the writer.
+func (l *Listener) handleRequest(ctx context.Context, w *data.Writer,
+	req *http.Request, is_json bool) (err error) {
+
+	var body io.Reader = req.Body
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return errs.New("unsupported content encoding: %q", encoding)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(body, maxBody+1))
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if len(buf) > maxBody {
+		return errs.New("request body too large")
+	}
+
+	var er exportRequest
+	if is_json {
+		err = errs.Wrap(json.Unmarshal(buf, &er))
+	} else {
+		er, err = parseExportRequest(buf)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.addRequest(ctx, w, er)
+	return nil
+}
@@ -0,0 +1,278 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/assert"
+	"github.com/vivint/rothko/internal/wire"
+)
+
+func TestListenerJSON(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	h := New("", Options{}).handler(ctx, w)
+
+	request := func(reqs string, at string) string {
+		return `{"resourceMetrics": [{
+			"resource": {"attributes": [
+				{"key": "service.name", "value": {"stringValue": "svc"}},
+				{"key": "host.name", "value": {"stringValue": "h"}}
+			]},
+			"scopeMetrics": [{"metrics": [
+				{"name": "temp", "gauge": {"dataPoints": [{
+					"attributes": [
+						{"key": "room", "value": {"stringValue": "kitchen"}},
+						{"key": "floor", "value": {"intValue": "2"}}
+					],
+					"asDouble": 20.5,
+					"exemplars": [{"asDouble": 20.5, "traceId": "0102"}]
+				}]}},
+				{"name": "reqs", "sum": {
+					"aggregationTemporality": 2,
+					"isMonotonic": true,
+					"dataPoints": [{
+						"timeUnixNano": "` + at + `",
+						"asInt": "` + reqs + `"
+					}]
+				}},
+				{"name": "lat", "histogram": {
+					"aggregationTemporality": 1,
+					"dataPoints": [{
+						"bucketCounts": ["1", "2", "1"],
+						"explicitBounds": [1, 2],
+						"max": 5,
+						"exemplars": [{"asDouble": 4.5, "traceId": "abcd"}]
+					}]
+				}}
+			]}]
+		}]}`
+	}
+
+	post(t, h, "application/json", request("100", "1000000000"))
+	post(t, h, "application/json", request("130", "11000000000"))
+
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"temp;floor=2;room=kitchen;service.name=svc": {
+			obs: 2, min: 20.5, max: 20.5, minId: "0102", maxId: "0102",
+		},
+		"reqs;service.name=svc": {
+			obs: 1, min: 3, max: 3,
+		},
+		"lat;service.name=svc": {
+			obs: 8, min: 0.5, max: 4.5, maxId: "abcd",
+		},
+	})
+}
+
+func TestListenerSeries(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	h := New("", Options{}).handler(ctx, w)
+
+	request := func(host, start, reqs, at string) string {
+		return `{"resourceMetrics": [{
+			"resource": {"attributes": [
+				{"key": "service.name", "value": {"stringValue": "svc"}},
+				{"key": "host.name", "value": {"stringValue": "` + host + `"}}
+			]},
+			"scopeMetrics": [{"metrics": [
+				{"name": "reqs", "sum": {
+					"aggregationTemporality": 2,
+					"isMonotonic": true,
+					"dataPoints": [{
+						"startTimeUnixNano": "` + start + `",
+						"timeUnixNano": "` + at + `",
+						"asInt": "` + reqs + `"
+					}]
+				}}
+			]}]
+		}]}`
+	}
+
+	// the hosts share a metric name but are tracked separately
+	post(t, h, "application/json", request("a", "1", "100", "1000000000"))
+	post(t, h, "application/json", request("b", "1", "1000", "1000000000"))
+	post(t, h, "application/json", request("a", "1", "130", "11000000000"))
+	post(t, h, "application/json", request("b", "1", "1050", "11000000000"))
+
+	// a new start time is a new series, so it is only a baseline
+	post(t, h, "application/json", request("a", "2", "5", "21000000000"))
+
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"reqs;service.name=svc": {obs: 2, min: 3, max: 5},
+	})
+}
+
+func TestListenerProtobuf(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	h := New("", Options{ResourceAttributes: []string{}}).handler(ctx, w)
+
+	// scale 0 has positive buckets (1, 2] and (2, 4] at indexes 0 and 1.
+	var buckets []byte
+	buckets = appendFieldVarint(buckets, 1, 0)
+	buckets = appendField(buckets, 2,
+		wire.AppendVarint(wire.AppendVarint(nil, 1), 2))
+
+	var point []byte
+	point = appendField(point, 1, appendKeyValue(nil, "k", "v"))
+	point = appendFieldVarint(point, 7, 1)
+	point = appendField(point, 8, buckets)
+
+	var eh []byte
+	eh = appendField(eh, 1, point)
+	eh = appendFieldVarint(eh, 2, 1)
+
+	var quantile []byte
+	quantile = wire.AppendDouble(wire.AppendKey(quantile, 1, wire.Fixed64), 0.5)
+	quantile = wire.AppendDouble(wire.AppendKey(quantile, 2, wire.Fixed64), 7)
+
+	var metrics []byte
+	metrics = appendField(metrics, 2, appendField(
+		appendField(nil, 1, []byte("exp")), 10, eh))
+	metrics = appendField(metrics, 2, appendField(
+		appendField(nil, 1, []byte("sum")), 11,
+		appendField(nil, 1, appendField(nil, 6, quantile))))
+
+	var rm []byte
+	rm = appendField(rm, 1, appendField(nil, 1,
+		appendKeyValue(nil, "service.name", "svc")))
+	rm = appendField(rm, 2, metrics)
+
+	post(t, h, "application/x-protobuf", string(appendField(nil, 1, rm)))
+
+	assert.DeepEqual(t, capture(ctx, w), map[string]result{
+		"exp;k=v":          {obs: 4, min: 0, max: 3},
+		"sum;quantile=0.5": {obs: 1, min: 7, max: 7},
+	})
+}
+
+func TestListenerInvalid(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	h := New("", Options{}).handler(ctx, w)
+
+	for _, content_type := range []string{
+		"application/json",
+		"application/x-protobuf",
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/v1/metrics",
+			bytes.NewReader([]byte("\xff\xff")))
+		req.Header.Set("Content-Type", content_type)
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusBadRequest)
+	}
+}
+
+func FuzzListenerProtobuf(f *testing.F) {
+	var buckets []byte
+	buckets = appendFieldVarint(buckets, 1, 0)
+	buckets = appendField(buckets, 2,
+		wire.AppendVarint(wire.AppendVarint(nil, 1), 2))
+
+	var point []byte
+	point = appendField(point, 1, appendKeyValue(nil, "k", "v"))
+	point = appendFieldVarint(point, 7, 1)
+	point = appendField(point, 8, buckets)
+
+	var eh []byte
+	eh = appendField(eh, 1, point)
+	eh = appendFieldVarint(eh, 2, 2)
+
+	metrics := appendField(nil, 2, appendField(
+		appendField(nil, 1, []byte("exp")), 10, eh))
+	f.Add(appendField(nil, 1, appendField(nil, 2, metrics)))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		ctx := context.Background()
+		w := data.NewWriter(fakeParams{})
+		h := New("", Options{}).handler(ctx, w)
+
+		// send everything twice so that cumulative histograms produce
+		// deltas.
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/v1/metrics",
+				bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			h.ServeHTTP(rec, req)
+			assert.That(t, rec.Code < 500)
+		}
+	})
+}
+
+//
+// helpers
+//
+
+type result struct {
+	obs          int64
+	min, max     float64
+	minId, maxId string
+}
+
+func capture(ctx context.Context, w *data.Writer) map[string]result {
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{
+				obs:   rec.Observations,
+				min:   rec.Min,
+				max:   rec.Max,
+				minId: string(rec.MinId),
+				maxId: string(rec.MaxId),
+			}
+			return true
+		})
+	return got
+}
+
+func post(t *testing.T, h http.Handler, content_type, body string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/v1/metrics",
+		bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", content_type)
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusOK)
+}
+
+func appendField(buf []byte, num int, data []byte) []byte {
+	return wire.AppendBytes(wire.AppendKey(buf, num, wire.Bytes), data)
+}
+
+func appendFieldVarint(buf []byte, num int, x uint64) []byte {
+	return wire.AppendVarint(wire.AppendKey(buf, num, wire.Varint), x)
+}
+
+func appendKeyValue(buf []byte, key, value string) []byte {
+	return appendField(appendField(buf, 1, []byte(key)), 2,
+		appendField(nil, 1, []byte(value)))
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeParams struct{ dist.Params }
+
+func (fakeParams) Kind() string            { return "fake" }
+func (fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
+
+type fakeDist struct{ dist.Dist }
+
+func (fakeDist) Kind() string                              { return "fake" }
+func (fakeDist) Observe(val float64)                       {}
+func (fakeDist) ObserveWeighted(val float64, weight int64) {}
+func (fakeDist) Marshal(x []byte) []byte                   { return x }
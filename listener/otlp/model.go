@@ -0,0 +1,241 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/zeebo/errs"
+)
+
+//
+// the subset of the OTLP metrics data model that we care about. the struct
+// tags match the OTLP/HTTP json encoding, and the protobuf encoding is
+// decoded into the same types by hand. see opentelemetry/proto/metrics/v1.
+//
+
+// aggregation temporalities
+const (
+	temporalityDelta      = 1
+	temporalityCumulative = 2
+)
+
+type exportRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name                 string        `json:"name"`
+	Gauge                *gauge        `json:"gauge"`
+	Sum                  *sum          `json:"sum"`
+	Histogram            *histogram    `json:"histogram"`
+	ExponentialHistogram *expHistogram `json:"exponentialHistogram"`
+	Summary              *summary      `json:"summary"`
+}
+
+type gauge struct {
+	DataPoints []numberPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberPoint `json:"dataPoints"`
+	AggregationTemporality int           `json:"aggregationTemporality"`
+	IsMonotonic            bool          `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramPoint `json:"dataPoints"`
+	AggregationTemporality int              `json:"aggregationTemporality"`
+}
+
+type expHistogram struct {
+	DataPoints             []expHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                 `json:"aggregationTemporality"`
+}
+
+type summary struct {
+	DataPoints []summaryPoint `json:"dataPoints"`
+}
+
+type numberPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano jsonUint   `json:"startTimeUnixNano"`
+	TimeUnixNano      jsonUint   `json:"timeUnixNano"`
+	AsDouble          *jsonFloat `json:"asDouble"`
+	AsInt             *jsonInt   `json:"asInt"`
+	Exemplars         []exemplar `json:"exemplars"`
+}
+
+type histogramPoint struct {
+	Attributes        []keyValue  `json:"attributes"`
+	StartTimeUnixNano jsonUint    `json:"startTimeUnixNano"`
+	TimeUnixNano      jsonUint    `json:"timeUnixNano"`
+	BucketCounts      []jsonUint  `json:"bucketCounts"`
+	ExplicitBounds    []jsonFloat `json:"explicitBounds"`
+	Exemplars         []exemplar  `json:"exemplars"`
+	Min               *jsonFloat  `json:"min"`
+	Max               *jsonFloat  `json:"max"`
+}
+
+type expHistogramPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano jsonUint   `json:"startTimeUnixNano"`
+	TimeUnixNano      jsonUint   `json:"timeUnixNano"`
+	Scale             int32      `json:"scale"`
+	ZeroCount         jsonUint   `json:"zeroCount"`
+	Positive          expBuckets `json:"positive"`
+	Negative          expBuckets `json:"negative"`
+	Exemplars         []exemplar `json:"exemplars"`
+	Min               *jsonFloat `json:"min"`
+	Max               *jsonFloat `json:"max"`
+}
+
+type expBuckets struct {
+	Offset       int32      `json:"offset"`
+	BucketCounts []jsonUint `json:"bucketCounts"`
+}
+
+type summaryPoint struct {
+	Attributes     []keyValue      `json:"attributes"`
+	QuantileValues []quantileValue `json:"quantileValues"`
+}
+
+type quantileValue struct {
+	Quantile jsonFloat `json:"quantile"`
+	Value    jsonFloat `json:"value"`
+}
+
+type exemplar struct {
+	AsDouble *jsonFloat `json:"asDouble"`
+	AsInt    *jsonInt   `json:"asInt"`
+	TraceId  hexBytes   `json:"traceId"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string    `json:"stringValue"`
+	BoolValue   *bool      `json:"boolValue"`
+	IntValue    *jsonInt   `json:"intValue"`
+	DoubleValue *jsonFloat `json:"doubleValue"`
+	BytesValue  []byte     `json:"bytesValue"`
+}
+
+// numberValue returns the value of a number data point or exemplar.
+func numberValue(d *jsonFloat, i *jsonInt) float64 {
+	switch {
+	case d != nil:
+		return float64(*d)
+	case i != nil:
+		return float64(*i)
+	default:
+		return 0
+	}
+}
+
+// String returns the value formatted as a string. Arrays and key value lists
+// are not supported and return an empty string.
+func (a anyValue) String() string {
+	switch {
+	case a.StringValue != nil:
+		return *a.StringValue
+	case a.BoolValue != nil:
+		return strconv.FormatBool(*a.BoolValue)
+	case a.IntValue != nil:
+		return strconv.FormatInt(int64(*a.IntValue), 10)
+	case a.DoubleValue != nil:
+		return strconv.FormatFloat(float64(*a.DoubleValue), 'g', -1, 64)
+	case a.BytesValue != nil:
+		return hex.EncodeToString(a.BytesValue)
+	default:
+		return ""
+	}
+}
+
+//
+// json helpers. 64 bit integers are encoded as strings, floats may be the
+// strings "NaN", "Infinity" or "-Infinity", and ids are hex encoded.
+//
+
+// jsonUint is a uint64 encoded as a number or a string.
+type jsonUint uint64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *jsonUint) UnmarshalJSON(data []byte) error {
+	x, err := strconv.ParseUint(string(unquote(data)), 10, 64)
+	*j = jsonUint(x)
+	return errs.Wrap(err)
+}
+
+// jsonInt is an int64 encoded as a number or a string.
+type jsonInt int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *jsonInt) UnmarshalJSON(data []byte) error {
+	x, err := strconv.ParseInt(string(unquote(data)), 10, 64)
+	*j = jsonInt(x)
+	return errs.Wrap(err)
+}
+
+// jsonFloat is a float64 encoded as a number or a string.
+type jsonFloat float64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *jsonFloat) UnmarshalJSON(data []byte) error {
+	switch string(unquote(data)) {
+	case "NaN":
+		*j = jsonFloat(math.NaN())
+	case "Infinity":
+		*j = jsonFloat(math.Inf(1))
+	case "-Infinity":
+		*j = jsonFloat(math.Inf(-1))
+	default:
+		var x float64
+		if err := json.Unmarshal(unquote(data), &x); err != nil {
+			return errs.Wrap(err)
+		}
+		*j = jsonFloat(x)
+	}
+	return nil
+}
+
+// hexBytes is a byte slice encoded as a hex string.
+type hexBytes []byte
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errs.Wrap(err)
+	}
+	x, err := hex.DecodeString(s)
+	*h = hexBytes(x)
+	return errs.Wrap(err)
+}
+
+// unquote removes the quotes around a json string, if any.
+func unquote(data []byte) []byte {
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		return data[1 : len(data)-1]
+	}
+	return data
+}
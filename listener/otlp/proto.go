@@ -0,0 +1,313 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"github.com/vivint/rothko/internal/wire"
+)
+
+//
+// decoding of the OTLP protobuf encoding into the data model. the field
+// numbers come from opentelemetry/proto/metrics/v1/metrics.proto and
+// opentelemetry/proto/common/v1/common.proto.
+//
+
+// parseExportRequest parses a protobuf encoded ExportMetricsServiceRequest.
+func parseExportRequest(msg []byte) (req exportRequest, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var rm resourceMetrics
+		err := parseResourceMetrics(f.Data, &rm)
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+		return err
+	})
+	return req, err
+}
+
+func parseResourceMetrics(msg []byte, rm *resourceMetrics) error {
+	return wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				kv, err := parseKeyValue(f.Data)
+				rm.Resource.Attributes = append(rm.Resource.Attributes, kv)
+				return err
+			})
+		case 2, 1000:
+			// 1000 is the deprecated instrumentation_library_metrics that
+			// has the same layout as scope_metrics.
+			var sm scopeMetrics
+			err := wire.Iterate(f.Data, func(f wire.Field) error {
+				if f.Num != 2 {
+					return nil
+				}
+				m, err := parseMetric(f.Data)
+				sm.Metrics = append(sm.Metrics, m)
+				return err
+			})
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+			return err
+		}
+		return nil
+	})
+}
+
+func parseMetric(msg []byte) (m metric, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Name = f.String()
+		case 5:
+			m.Gauge = new(gauge)
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				p, err := parseNumberPoint(f.Data)
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, p)
+				return err
+			})
+		case 7:
+			m.Sum = new(sum)
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					p, err := parseNumberPoint(f.Data)
+					m.Sum.DataPoints = append(m.Sum.DataPoints, p)
+					return err
+				case 2:
+					m.Sum.AggregationTemporality = int(f.Int)
+				case 3:
+					m.Sum.IsMonotonic = f.Int != 0
+				}
+				return nil
+			})
+		case 9:
+			m.Histogram = new(histogram)
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					p, err := parseHistogramPoint(f.Data)
+					m.Histogram.DataPoints = append(m.Histogram.DataPoints, p)
+					return err
+				case 2:
+					m.Histogram.AggregationTemporality = int(f.Int)
+				}
+				return nil
+			})
+		case 10:
+			m.ExponentialHistogram = new(expHistogram)
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				eh := m.ExponentialHistogram
+				switch f.Num {
+				case 1:
+					p, err := parseExpHistogramPoint(f.Data)
+					eh.DataPoints = append(eh.DataPoints, p)
+					return err
+				case 2:
+					eh.AggregationTemporality = int(f.Int)
+				}
+				return nil
+			})
+		case 11:
+			m.Summary = new(summary)
+			return wire.Iterate(f.Data, func(f wire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				p, err := parseSummaryPoint(f.Data)
+				m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+				return err
+			})
+		}
+		return nil
+	})
+	return m, err
+}
+
+func parseNumberPoint(msg []byte) (p numberPoint, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 2:
+			p.StartTimeUnixNano = jsonUint(f.Int)
+		case 3:
+			p.TimeUnixNano = jsonUint(f.Int)
+		case 4:
+			p.AsDouble = newFloat(f.Double())
+		case 5:
+			e, err := parseExemplar(f.Data)
+			p.Exemplars = append(p.Exemplars, e)
+			return err
+		case 6:
+			p.AsInt = newInt(f.Int64())
+		case 7:
+			kv, err := parseKeyValue(f.Data)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseHistogramPoint(msg []byte) (p histogramPoint, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 2:
+			p.StartTimeUnixNano = jsonUint(f.Int)
+		case 3:
+			p.TimeUnixNano = jsonUint(f.Int)
+		case 6:
+			return f.PackedFixed64s(func(x uint64) {
+				p.BucketCounts = append(p.BucketCounts, jsonUint(x))
+			})
+		case 7:
+			return f.PackedDoubles(func(x float64) {
+				p.ExplicitBounds = append(p.ExplicitBounds, jsonFloat(x))
+			})
+		case 8:
+			e, err := parseExemplar(f.Data)
+			p.Exemplars = append(p.Exemplars, e)
+			return err
+		case 9:
+			kv, err := parseKeyValue(f.Data)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 11:
+			p.Min = newFloat(f.Double())
+		case 12:
+			p.Max = newFloat(f.Double())
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseExpHistogramPoint(msg []byte) (p expHistogramPoint, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			kv, err := parseKeyValue(f.Data)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 2:
+			p.StartTimeUnixNano = jsonUint(f.Int)
+		case 3:
+			p.TimeUnixNano = jsonUint(f.Int)
+		case 6:
+			p.Scale = int32(f.Sint64())
+		case 7:
+			p.ZeroCount = jsonUint(f.Int)
+		case 8:
+			return parseExpBuckets(f.Data, &p.Positive)
+		case 9:
+			return parseExpBuckets(f.Data, &p.Negative)
+		case 11:
+			e, err := parseExemplar(f.Data)
+			p.Exemplars = append(p.Exemplars, e)
+			return err
+		case 12:
+			p.Min = newFloat(f.Double())
+		case 13:
+			p.Max = newFloat(f.Double())
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseExpBuckets(msg []byte, b *expBuckets) error {
+	return wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			b.Offset = int32(f.Sint64())
+		case 2:
+			return f.PackedVarints(func(x uint64) {
+				b.BucketCounts = append(b.BucketCounts, jsonUint(x))
+			})
+		}
+		return nil
+	})
+}
+
+func parseSummaryPoint(msg []byte) (p summaryPoint, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 6:
+			var qv quantileValue
+			err := wire.Iterate(f.Data, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					qv.Quantile = jsonFloat(f.Double())
+				case 2:
+					qv.Value = jsonFloat(f.Double())
+				}
+				return nil
+			})
+			p.QuantileValues = append(p.QuantileValues, qv)
+			return err
+		case 7:
+			kv, err := parseKeyValue(f.Data)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseExemplar(msg []byte) (e exemplar, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 3:
+			e.AsDouble = newFloat(f.Double())
+		case 5:
+			e.TraceId = hexBytes(f.Data)
+		case 6:
+			e.AsInt = newInt(f.Int64())
+		}
+		return nil
+	})
+	return e, err
+}
+
+func parseKeyValue(msg []byte) (kv keyValue, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			kv.Key = f.String()
+		case 2:
+			return parseAnyValue(f.Data, &kv.Value)
+		}
+		return nil
+	})
+	return kv, err
+}
+
+func parseAnyValue(msg []byte, v *anyValue) error {
+	return wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			s := f.String()
+			v.StringValue = &s
+		case 2:
+			b := f.Int != 0
+			v.BoolValue = &b
+		case 3:
+			v.IntValue = newInt(f.Int64())
+		case 4:
+			v.DoubleValue = newFloat(f.Double())
+		case 7:
+			v.BytesValue = append([]byte{}, f.Data...)
+		}
+		return nil
+	})
+}
+
+func newFloat(x float64) *jsonFloat { y := jsonFloat(x); return &y }
+func newInt(x int64) *jsonInt       { y := jsonInt(x); return &y }
@@ -0,0 +1,38 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package otlp
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/listener"
+	"github.com/vivint/rothko/registry"
+)
+
+func init() {
+	registry.RegisterListener("otlp", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			address := a.I("address").String()
+			path := a.I("path").String()
+
+			var resource_attributes []string
+			if a.I("resource_attributes").V() != nil {
+				resource_attributes = make([]string,
+					a.I("resource_attributes").Len())
+				for i := range resource_attributes {
+					resource_attributes[i] = a.I("resource_attributes").
+						N(i).String()
+				}
+			}
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			return New(address, Options{
+				Path:               path,
+				ResourceAttributes: resource_attributes,
+			}), nil
+		}))
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/cumulative"
+	"github.com/vivint/rothko/listener"
+)
+
+// metricName returns the name of the metric with the labels added as
+// graphite tags, like "name;a=b;c=d". The __name__ label is skipped.
+func metricName(name string, labels []label) string {
+	tags := make([]listener.Tag, 0, len(labels))
+	for _, l := range labels {
+		if l.name != "__name__" {
+			tags = append(tags, listener.Tag{Key: l.name, Value: l.value})
+		}
+	}
+	return listener.MetricName(name, tags)
+}
+
+// withoutLabel returns the labels without any with the given name.
+func withoutLabel(labels []label, name string) []label {
+	out := make([]label, 0, len(labels))
+	for _, l := range labels {
+		if l.name != name {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// observe adds the value to the writer count times.
+func observe(ctx context.Context, w *data.Writer, metric string,
+	value, count float64) {
+
+	w.AddWeighted(ctx, metric, value, int64(math.Round(count)), nil)
+}
+
+// bucket is a histogram bucket with some count of observations.
+type bucket struct {
+	key   string
+	value float64
+	count float64
+}
+
+// updateDeltas replaces the cumulative counts of the buckets with the
+// counts since the last update of the series, returning false if the series
+// has not been seen before.
+func updateDeltas(t *cumulative.Tracker, series string,
+	buckets []bucket) bool {
+
+	keys := make([]string, len(buckets))
+	counts := make([]float64, len(buckets))
+	for i, b := range buckets {
+		keys[i], counts[i] = b.key, b.count
+	}
+	if !t.Deltas(series, keys, counts) {
+		return false
+	}
+	for i := range buckets {
+		buckets[i].count = counts[i]
+	}
+	return true
+}
+
+// classicBucket is a bucket from a classic histogram with a cumulative count
+// of observations less than or equal to the bound.
+type classicBucket struct {
+	le    float64
+	count float64
+}
+
+// classicBuckets converts the cumulative classic histogram buckets into
+// buckets holding the observations for the midpoint of each bucket. The
+// +Inf bucket uses its lower bound.
+func classicBuckets(cumulative []classicBucket) []bucket {
+	sort.Slice(cumulative, func(i, j int) bool {
+		return cumulative[i].le < cumulative[j].le
+	})
+
+	buckets := make([]bucket, 0, len(cumulative))
+	lower, last := 0.0, 0.0
+	for i, cb := range cumulative {
+		if i == 0 && cb.le < 0 {
+			lower = cb.le
+		}
+
+		value := (lower + cb.le) / 2
+		if math.IsInf(cb.le, 1) {
+			value = lower
+		}
+
+		buckets = append(buckets, bucket{
+			key:   strconv.FormatFloat(cb.le, 'g', -1, 64),
+			value: value,
+			count: cb.count - last,
+		})
+		lower, last = cb.le, cb.count
+	}
+	return buckets
+}
+
+// nativeBuckets converts the native histogram into buckets holding the
+// absolute count of observations for the midpoint of each bucket.
+func nativeBuckets(h histogram) []bucket {
+	base := math.Pow(2, math.Pow(2, -float64(h.schema)))
+
+	buckets := []bucket{{key: "0", value: 0, count: h.zeroCount}}
+	buckets = appendNative(buckets, "+", base, 1,
+		h.positive, h.positiveInts, h.positiveFlts)
+	buckets = appendNative(buckets, "-", base, -1,
+		h.negative, h.negativeInts, h.negativeFlts)
+	return buckets
+}
+
+// appendNative appends the buckets described by the spans and either the
+// integer deltas or the float counts. Bucket i covers (base^(i-1), base^i],
+// negated by the sign.
+func appendNative(buckets []bucket, prefix string, base, sign float64,
+	spans []bucketSpan, ints []int64, flts []float64) []bucket {
+
+	index, pos, count := int64(0), 0, int64(0)
+	for _, span := range spans {
+		index += int64(span.offset)
+		for j := uint32(0); j < span.length; j++ {
+			var value float64
+			switch {
+			case pos < len(ints):
+				count += ints[pos]
+				value = float64(count)
+			case pos < len(flts):
+				value = flts[pos]
+			default:
+				return buckets
+			}
+
+			lower := math.Pow(base, float64(index-1))
+			upper := math.Pow(base, float64(index))
+
+			buckets = append(buckets, bucket{
+				key:   prefix + strconv.FormatInt(index, 10),
+				value: sign * (lower + upper) / 2,
+				count: value,
+			})
+
+			index++
+			pos++
+		}
+	}
+	return buckets
+}
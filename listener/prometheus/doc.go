@@ -0,0 +1,4 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package prometheus provides listeners for prometheus data.
+package prometheus
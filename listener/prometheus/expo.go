@@ -0,0 +1,160 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/errs"
+)
+
+// exposition is the parsed form of the prometheus text exposition format.
+type exposition struct {
+	types   map[string]string
+	samples []expoSample
+}
+
+// expoSample is a single sample line from the text exposition format.
+type expoSample struct {
+	name   string
+	labels []label
+	value  float64
+}
+
+// typeSuffixes are the suffixes that may be added to the name of a metric
+// family for the samples that belong to it.
+var typeSuffixes = []string{"_bucket", "_sum", "_count", "_total", "_created"}
+
+// typeOf returns the type of the metric family the sample name belongs to,
+// or "untyped" if it is not known.
+func (e exposition) typeOf(name string) string {
+	if typ, ok := e.types[name]; ok {
+		return typ
+	}
+	for _, suffix := range typeSuffixes {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if typ, ok := e.types[strings.TrimSuffix(name, suffix)]; ok {
+			return typ
+		}
+	}
+	return "untyped"
+}
+
+// parseExposition parses the text exposition format from the reader.
+func parseExposition(r io.Reader) (e exposition, err error) {
+	e.types = make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '#' {
+			fields := strings.Fields(string(line[1:]))
+			if len(fields) >= 3 && fields[0] == "TYPE" {
+				e.types[fields[1]] = strings.ToLower(fields[2])
+			}
+			continue
+		}
+
+		sample, err := parseSample(string(line))
+		if err != nil {
+			return exposition{}, errs.New("invalid line %q: %v", line, err)
+		}
+		e.samples = append(e.samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return exposition{}, errs.Wrap(err)
+	}
+
+	return e, nil
+}
+
+// parseSample parses a sample line, like `name{a="b"} 1 1234`. The
+// timestamp is ignored.
+func parseSample(line string) (s expoSample, err error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end == -1 {
+		return expoSample{}, errs.New("missing value")
+	}
+	s.name, line = line[:end], line[end:]
+	if s.name == "" {
+		return expoSample{}, errs.New("missing name")
+	}
+
+	if line[0] == '{' {
+		s.labels, line, err = parseLabels(line[1:])
+		if err != nil {
+			return expoSample{}, err
+		}
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return expoSample{}, errs.New("bad number of fields: %d", len(fields))
+	}
+
+	s.value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return expoSample{}, errs.Wrap(err)
+	}
+
+	return s, nil
+}
+
+// parseLabels parses the labels after the opening brace, returning the rest
+// of the line after the closing brace.
+func parseLabels(line string) (labels []label, rest string, err error) {
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return nil, "", errs.New("unterminated labels")
+		}
+		if line[0] == '}' {
+			return labels, line[1:], nil
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, "", errs.New("missing label name")
+		}
+		name := strings.TrimSpace(line[:eq])
+		line = strings.TrimLeft(line[eq+1:], " \t")
+
+		if line == "" || line[0] != '"' {
+			return nil, "", errs.New("missing label value")
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			ch := line[i]
+			if ch == '\\' && i+1 < len(line) {
+				i++
+				switch ch = line[i]; ch {
+				case 'n':
+					ch = '\n'
+				}
+			}
+			value.WriteByte(ch)
+		}
+		if i >= len(line) {
+			return nil, "", errs.New("unterminated label value")
+		}
+		labels = append(labels, label{name: name, value: value.String()})
+
+		line = strings.TrimLeft(line[i+1:], " \t")
+		if line != "" && line[0] == ',' {
+			line = line[1:]
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"github.com/vivint/rothko/internal/wire"
+)
+
+//
+// decoding for the subset of the prometheus remote write protobufs that we
+// care about. see prometheus/prompb/types.proto and remote.proto.
+//
+
+// writeRequest is a prometheus remote write request.
+type writeRequest struct {
+	series []timeSeries
+}
+
+// timeSeries is a set of samples or histograms for some labels.
+type timeSeries struct {
+	labels     []label
+	samples    []sample
+	histograms []histogram
+}
+
+// label is a name and value pair.
+type label struct {
+	name  string
+	value string
+}
+
+// sample is a value at some timestamp in milliseconds.
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+// histogram is a native histogram. Integer histograms have their bucket
+// counts as deltas from the previous bucket, and float histograms have them
+// as absolute counts.
+type histogram struct {
+	count         float64
+	schema        int32
+	zeroThreshold float64
+	zeroCount     float64
+	negative      []bucketSpan
+	negativeInts  []int64
+	negativeFlts  []float64
+	positive      []bucketSpan
+	positiveInts  []int64
+	positiveFlts  []float64
+	resetHint     int
+	timestamp     int64
+}
+
+// bucketSpan is a run of consecutive buckets in a native histogram.
+type bucketSpan struct {
+	offset int32
+	length uint32
+}
+
+// histogram reset hints
+const (
+	resetHintGauge = 3
+)
+
+// parseWriteRequest parses the protobuf encoded remote write request.
+func parseWriteRequest(msg []byte) (req writeRequest, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		if f.Num != 1 || f.Type != wire.Bytes {
+			return nil
+		}
+		ts, err := parseTimeSeries(f.Data)
+		if err != nil {
+			return err
+		}
+		req.series = append(req.series, ts)
+		return nil
+	})
+	return req, err
+}
+
+// parseTimeSeries parses a protobuf encoded time series.
+func parseTimeSeries(msg []byte) (ts timeSeries, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		if f.Type != wire.Bytes {
+			return nil
+		}
+
+		switch f.Num {
+		case 1:
+			var l label
+			err := wire.Iterate(f.Data, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					l.name = f.String()
+				case 2:
+					l.value = f.String()
+				}
+				return nil
+			})
+			ts.labels = append(ts.labels, l)
+			return err
+
+		case 2:
+			var s sample
+			err := wire.Iterate(f.Data, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					s.value = f.Double()
+				case 2:
+					s.timestamp = f.Int64()
+				}
+				return nil
+			})
+			ts.samples = append(ts.samples, s)
+			return err
+
+		case 4:
+			h, err := parseHistogram(f.Data)
+			ts.histograms = append(ts.histograms, h)
+			return err
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// parseHistogram parses a protobuf encoded native histogram.
+func parseHistogram(msg []byte) (h histogram, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			h.count = float64(f.Int)
+		case 2:
+			h.count = f.Double()
+		case 4:
+			h.schema = int32(f.Sint64())
+		case 5:
+			h.zeroThreshold = f.Double()
+		case 6:
+			h.zeroCount = float64(f.Int)
+		case 7:
+			h.zeroCount = f.Double()
+		case 8:
+			span, err := parseBucketSpan(f.Data)
+			h.negative = append(h.negative, span)
+			return err
+		case 9:
+			return f.PackedSint64s(func(x int64) {
+				h.negativeInts = append(h.negativeInts, x)
+			})
+		case 10:
+			return f.PackedDoubles(func(x float64) {
+				h.negativeFlts = append(h.negativeFlts, x)
+			})
+		case 11:
+			span, err := parseBucketSpan(f.Data)
+			h.positive = append(h.positive, span)
+			return err
+		case 12:
+			return f.PackedSint64s(func(x int64) {
+				h.positiveInts = append(h.positiveInts, x)
+			})
+		case 13:
+			return f.PackedDoubles(func(x float64) {
+				h.positiveFlts = append(h.positiveFlts, x)
+			})
+		case 14:
+			h.resetHint = int(f.Int)
+		case 15:
+			h.timestamp = f.Int64()
+		}
+		return nil
+	})
+	return h, err
+}
+
+// parseBucketSpan parses a protobuf encoded bucket span.
+func parseBucketSpan(msg []byte) (span bucketSpan, err error) {
+	err = wire.Iterate(msg, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			span.offset = int32(f.Sint64())
+		case 2:
+			span.length = uint32(f.Int)
+		}
+		return nil
+	})
+	return span, err
+}
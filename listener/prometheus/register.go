@@ -0,0 +1,65 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"context"
+	"time"
+
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/listener"
+	"github.com/vivint/rothko/registry"
+	"github.com/zeebo/errs"
+)
+
+func init() {
+	registry.RegisterListener("remote_write", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			address := a.I("address").String()
+			path := a.I("path").String()
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			return NewRemoteWrite(address, RemoteWriteOptions{
+				Path: path,
+			}), nil
+		}))
+
+	registry.RegisterListener("scrape", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			targets := make([]string, a.I("targets").Len())
+			for i := range targets {
+				targets[i] = a.I("targets").N(i).String()
+			}
+			interval := a.I("interval").String()
+			timeout := a.I("timeout").String()
+			name := a.I("name").String()
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			opts := ScrapeOptions{
+				Targets: targets,
+				Name:    name,
+			}
+
+			var err error
+			if interval != "" {
+				opts.Interval, err = time.ParseDuration(interval)
+				if err != nil {
+					return nil, errs.Wrap(err)
+				}
+			}
+			if timeout != "" {
+				opts.Timeout, err = time.ParseDuration(timeout)
+				if err != nil {
+					return nil, errs.Wrap(err)
+				}
+			}
+
+			return NewScrape(opts)
+		}))
+}
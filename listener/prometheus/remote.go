@@ -0,0 +1,185 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/internal/cumulative"
+	"github.com/vivint/rothko/internal/snappy"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+const (
+	// maxCompressed is the largest compressed body accepted.
+	maxCompressed = 32 << 20
+
+	// maxDecompressed is the largest a body is allowed to decompress to.
+	maxDecompressed = 128 << 20
+)
+
+// RemoteWriteOptions controls the behavior of the remote write listener.
+type RemoteWriteOptions struct {
+	// Path is the http path that accepts writes. Defaults to
+	// "/api/v1/write".
+	Path string
+}
+
+// RemoteWriteListener implements the listener.Listener for the prometheus
+// remote write protocol.
+type RemoteWriteListener struct {
+	address string
+	opts    RemoteWriteOptions
+	tracker *cumulative.Tracker
+}
+
+// NewRemoteWrite returns a RemoteWriteListener that when Run will listen on
+// the provided address.
+func NewRemoteWrite(address string,
+	opts RemoteWriteOptions) *RemoteWriteListener {
+
+	if opts.Path == "" {
+		opts.Path = "/api/v1/write"
+	}
+
+	return &RemoteWriteListener{
+		address: address,
+		opts:    opts,
+		tracker: cumulative.NewTracker(0),
+	}
+}
+
+// Run listens on the address and writes all of the metrics to the writer.
+func (l *RemoteWriteListener) Run(ctx context.Context, w *data.Writer) (
+	err error) {
+
+	mux := http.NewServeMux()
+	mux.Handle(l.opts.Path, l.handler(ctx, w))
+	return listener.RunHTTP(ctx, l.address, mux)
+}
+
+// handler returns an http.Handler that adds the metrics from remote write
+// requests to the writer.
+func (l *RemoteWriteListener) handler(ctx context.Context,
+	w *data.Writer) http.Handler {
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := l.handleRequest(ctx, w, req)
+		if err != nil {
+			external.Errorw("invalid remote write request",
+				"peer", req.RemoteAddr,
+				"err", err.Error(),
+			)
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleRequest decodes the remote write request and adds it to the writer.
+func (l *RemoteWriteListener) handleRequest(ctx context.Context,
+	w *data.Writer, req *http.Request) (err error) {
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxCompressed+1))
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if len(body) > maxCompressed {
+		return errs.New("request body too large")
+	}
+
+	body, err = snappy.Decode(nil, body, maxDecompressed)
+	if err != nil {
+		return err
+	}
+
+	wr, err := parseWriteRequest(body)
+	if err != nil {
+		return err
+	}
+
+	l.addRequest(ctx, w, wr)
+	return nil
+}
+
+// addRequest adds all of the series in the write request to the writer.
+func (l *RemoteWriteListener) addRequest(ctx context.Context, w *data.Writer,
+	wr writeRequest) {
+
+	// classic histogram buckets are spread across many series, so we gather
+	// them up by the series they belong to first.
+	classic := make(map[string][]classicBucket)
+
+	for _, ts := range wr.series {
+		name := ""
+		le := ""
+		for _, lab := range ts.labels {
+			switch lab.name {
+			case "__name__":
+				name = lab.value
+			case "le":
+				le = lab.value
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		if le != "" && strings.HasSuffix(name, "_bucket") &&
+			len(ts.samples) > 0 {
+
+			bound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				continue
+			}
+
+			metric := metricName(strings.TrimSuffix(name, "_bucket"),
+				withoutLabel(ts.labels, "le"))
+			classic[metric] = append(classic[metric], classicBucket{
+				le:    bound,
+				count: ts.samples[len(ts.samples)-1].value,
+			})
+			continue
+		}
+
+		metric := metricName(name, ts.labels)
+		for _, s := range ts.samples {
+			w.Add(ctx, metric, s.value, nil)
+		}
+		for _, h := range ts.histograms {
+			buckets := nativeBuckets(h)
+			if h.resetHint != resetHintGauge &&
+				!updateDeltas(l.tracker, metric, buckets) {
+				continue
+			}
+			for _, b := range buckets {
+				observe(ctx, w, metric, b.value, b.count)
+			}
+		}
+	}
+
+	for metric, cumulative := range classic {
+		buckets := classicBuckets(cumulative)
+		if !updateDeltas(l.tracker, metric, buckets) {
+			continue
+		}
+		for _, b := range buckets {
+			observe(ctx, w, metric, b.value, b.count)
+		}
+	}
+}
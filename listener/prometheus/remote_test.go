@@ -0,0 +1,188 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/assert"
+	"github.com/vivint/rothko/internal/snappy"
+	"github.com/vivint/rothko/internal/wire"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l := NewRemoteWrite("", RemoteWriteOptions{})
+	h := l.handler(ctx, w)
+
+	classic := func(inf, one, two float64) []byte {
+		var req []byte
+		for _, b := range []struct {
+			le    string
+			count float64
+		}{{"+Inf", inf}, {"1", one}, {"2", two}} {
+			req = appendSeries(req,
+				[]string{"__name__", "lat_bucket", "job", "a", "le", b.le},
+				appendSample(nil, b.count, 0), nil)
+		}
+		return req
+	}
+
+	// schema 0 has buckets (1, 2] and (2, 4] at indexes 1 and 2.
+	var native []byte
+	native = appendFieldVarint(native, 1, 4)
+	native = appendFieldVarint(native, 6, 1)
+	native = appendFieldVarint(native, 14, resetHintGauge)
+	native = appendField(native, 11,
+		appendFieldVarint(appendFieldVarint(nil, 1, 2), 2, 2))
+	native = appendField(native, 12,
+		wire.AppendSint64(wire.AppendSint64(nil, 2), -1))
+
+	var first []byte
+	first = appendSeries(first,
+		[]string{"job", "a", "__name__", "up", "instance", "b", "empty", ""},
+		appendSample(nil, 1, 0), nil)
+	first = appendSeries(first,
+		[]string{"__name__", "native", "tag", "a;b"}, nil, native)
+	first = append(first, classic(2, 1, 2)...)
+
+	// the first sighting of the classic histogram is only a baseline.
+	post(t, h, first)
+	post(t, h, classic(6, 3, 5))
+
+	type result struct {
+		obs      int64
+		min, max float64
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{rec.Observations, rec.Min, rec.Max}
+			return true
+		})
+
+	assert.DeepEqual(t, got, map[string]result{
+		"up;instance=b;job=a": {1, 1, 1},
+		"native;tag=a_b":      {4, 0, 3},
+		"lat;job=a":           {4, 0.5, 2},
+	})
+}
+
+func TestRemoteWriteInvalid(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	h := NewRemoteWrite("", RemoteWriteOptions{}).handler(ctx, w)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/write",
+		bytes.NewReader([]byte("not snappy"))))
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/write", nil))
+	assert.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+}
+
+func FuzzRemoteWrite(f *testing.F) {
+	var native []byte
+	native = appendFieldVarint(native, 1, 4)
+	native = appendField(native, 11,
+		appendFieldVarint(appendFieldVarint(nil, 1, 2), 2, 2))
+	native = appendField(native, 12,
+		wire.AppendSint64(wire.AppendSint64(nil, 2), -1))
+
+	f.Add(appendSeries(nil, []string{"__name__", "up", "job", "a"},
+		appendSample(nil, 1, 0), nil))
+	f.Add(appendSeries(nil, []string{"__name__", "native"}, nil, native))
+	f.Add(appendSeries(nil, []string{"__name__", "lat_bucket", "le", "1"},
+		appendSample(nil, 1, 0), nil))
+
+	f.Fuzz(func(t *testing.T, req []byte) {
+		ctx := context.Background()
+		w := data.NewWriter(fakeParams{})
+		h := NewRemoteWrite("", RemoteWriteOptions{}).handler(ctx, w)
+
+		// send everything twice so that cumulative histograms produce
+		// deltas.
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/write",
+				bytes.NewReader(snappy.EncodeLiteral(nil, req))))
+			assert.That(t, rec.Code == http.StatusNoContent ||
+				rec.Code == http.StatusBadRequest)
+		}
+	})
+}
+
+//
+// helpers for building write requests
+//
+
+func post(t *testing.T, h http.Handler, req []byte) {
+	t.Helper()
+
+	body := snappy.EncodeLiteral(nil, req)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/write",
+		bytes.NewReader(body)))
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+}
+
+func appendField(buf []byte, num int, data []byte) []byte {
+	return wire.AppendBytes(wire.AppendKey(buf, num, wire.Bytes), data)
+}
+
+func appendFieldVarint(buf []byte, num int, x uint64) []byte {
+	return wire.AppendVarint(wire.AppendKey(buf, num, wire.Varint), x)
+}
+
+func appendSample(buf []byte, value float64, timestamp int64) []byte {
+	var sample []byte
+	sample = wire.AppendDouble(wire.AppendKey(sample, 1, wire.Fixed64), value)
+	sample = appendFieldVarint(sample, 2, uint64(timestamp))
+	return appendField(buf, 2, sample)
+}
+
+func appendSeries(buf []byte, labels []string, samples []byte,
+	histogram []byte) []byte {
+
+	var series []byte
+	for i := 0; i+1 < len(labels); i += 2 {
+		var label []byte
+		label = wire.AppendString(wire.AppendKey(label, 1, wire.Bytes),
+			labels[i])
+		label = wire.AppendString(wire.AppendKey(label, 2, wire.Bytes),
+			labels[i+1])
+		series = appendField(series, 1, label)
+	}
+	series = append(series, samples...)
+	if histogram != nil {
+		series = appendField(series, 4, histogram)
+	}
+	return appendField(buf, 1, series)
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeParams struct{ dist.Params }
+
+func (fakeParams) Kind() string            { return "fake" }
+func (fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
+
+type fakeDist struct{ dist.Dist }
+
+func (fakeDist) Kind() string                              { return "fake" }
+func (fakeDist) Observe(val float64)                       {}
+func (fakeDist) ObserveWeighted(val float64, weight int64) {}
+func (fakeDist) Marshal(x []byte) []byte                   { return x }
@@ -0,0 +1,277 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/internal/cumulative"
+	"github.com/zeebo/errs"
+)
+
+// maxScrapeBody is the largest response body accepted from a target.
+const maxScrapeBody = 32 << 20
+
+// ScrapeOptions controls the behavior of the scrape listener.
+type ScrapeOptions struct {
+	// Targets are the urls of the prometheus text endpoints to scrape.
+	Targets []string
+
+	// Interval controls how often the targets are scraped. Defaults to 1
+	// minute.
+	Interval time.Duration
+
+	// Timeout bounds how long a scrape of a target can take. Defaults to 10
+	// seconds.
+	Timeout time.Duration
+
+	// Name is a text/template that produces the metric name for a sample.
+	// It is executed with a NameData. Defaults to "{{ .Name }}{{ .Tags }}".
+	Name string
+}
+
+// NameData is the data available to the name template of the scrape
+// listener.
+type NameData struct {
+	// Name is the name of the sample, without any labels.
+	Name string
+
+	// Tags are the labels of the sample sorted by their name in the graphite
+	// tagged form, like ";a=b;c=d".
+	Tags string
+
+	// Labels are the labels of the sample.
+	Labels map[string]string
+
+	// Target is the url that was scraped.
+	Target string
+
+	// Instance is the host and port of the url that was scraped.
+	Instance string
+}
+
+// ScrapeListener implements the listener.Listener by periodically scraping
+// prometheus text endpoints. Gauges and untyped samples are added directly,
+// counters are added as their rate per second, and histogram buckets are
+// expanded into observations.
+type ScrapeListener struct {
+	opts    ScrapeOptions
+	name    *template.Template
+	client  *http.Client
+	tracker *cumulative.Tracker
+}
+
+// NewScrape returns a ScrapeListener that when Run will scrape the targets.
+func NewScrape(opts ScrapeOptions) (*ScrapeListener, error) {
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Name == "" {
+		opts.Name = "{{ .Name }}{{ .Tags }}"
+	}
+
+	name, err := template.New("name").Parse(opts.Name)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return &ScrapeListener{
+		opts:    opts,
+		name:    name,
+		client:  &http.Client{Timeout: opts.Timeout},
+		tracker: cumulative.NewTracker(3 * opts.Interval),
+	}, nil
+}
+
+// Run scrapes the targets every interval and writes all of the metrics to
+// the writer.
+func (l *ScrapeListener) Run(ctx context.Context, w *data.Writer) (
+	err error) {
+
+	ticker := time.NewTicker(l.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		l.scrapeAll(ctx, w, time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// scrapeAll scrapes all of the targets concurrently, logging any errors.
+func (l *ScrapeListener) scrapeAll(ctx context.Context, w *data.Writer,
+	now time.Time) {
+
+	var wg sync.WaitGroup
+	for _, target := range l.opts.Targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+
+			err := l.scrape(ctx, w, target, now)
+			if err != nil {
+				external.Errorw("scrape failed",
+					"target", target,
+					"err", err.Error(),
+				)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+// scrape fetches the target and adds the samples to the writer.
+func (l *ScrapeListener) scrape(ctx context.Context, w *data.Writer,
+	target string, now time.Time) (err error) {
+
+	ctx, cancel := context.WithTimeout(ctx, l.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errs.New("unexpected status: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScrapeBody+1))
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if len(body) > maxScrapeBody {
+		return errs.New("response body too large")
+	}
+
+	e, err := parseExposition(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	instance := target
+	if u, err := url.Parse(target); err == nil {
+		instance = u.Host
+	}
+
+	return l.addExposition(ctx, w, target, instance, now, e)
+}
+
+// addExposition adds all of the samples in the exposition to the writer.
+func (l *ScrapeListener) addExposition(ctx context.Context, w *data.Writer,
+	target, instance string, now time.Time, e exposition) (err error) {
+
+	// classic histogram buckets are spread across many samples, so we gather
+	// them up by the series they belong to first.
+	classic := make(map[string][]classicBucket)
+
+	for _, s := range e.samples {
+		typ := e.typeOf(s.name)
+
+		if typ == "histogram" && strings.HasSuffix(s.name, "_bucket") {
+			le := ""
+			for _, lab := range s.labels {
+				if lab.name == "le" {
+					le = lab.value
+				}
+			}
+			bound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				continue
+			}
+
+			metric, err := l.metricName(
+				strings.TrimSuffix(s.name, "_bucket"),
+				withoutLabel(s.labels, "le"), target, instance)
+			if err != nil {
+				return err
+			}
+			classic[metric] = append(classic[metric], classicBucket{
+				le:    bound,
+				count: s.value,
+			})
+			continue
+		}
+
+		metric, err := l.metricName(s.name, s.labels, target, instance)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case typ == "counter",
+			(typ == "histogram" || typ == "summary") &&
+				(strings.HasSuffix(s.name, "_sum") ||
+					strings.HasSuffix(s.name, "_count")):
+
+			series := target + "\x00" + metric
+			if rate, ok := l.tracker.Rate(series, s.value, now); ok {
+				w.Add(ctx, metric, rate, nil)
+			}
+
+		default:
+			w.Add(ctx, metric, s.value, nil)
+		}
+	}
+
+	for metric, cumulative := range classic {
+		buckets := classicBuckets(cumulative)
+		if !updateDeltas(l.tracker, target+"\x00"+metric, buckets) {
+			continue
+		}
+		for _, b := range buckets {
+			observe(ctx, w, metric, b.value, b.count)
+		}
+	}
+
+	return nil
+}
+
+// metricName executes the name template for the sample.
+func (l *ScrapeListener) metricName(name string, labels []label,
+	target, instance string) (string, error) {
+
+	nd := NameData{
+		Name:     name,
+		Tags:     metricName("", labels),
+		Labels:   make(map[string]string, len(labels)),
+		Target:   target,
+		Instance: instance,
+	}
+	for _, lab := range labels {
+		nd.Labels[lab.name] = lab.value
+	}
+
+	var buf strings.Builder
+	if err := l.name.Execute(&buf, nd); err != nil {
+		return "", errs.Wrap(err)
+	}
+	return buf.String(), nil
+}
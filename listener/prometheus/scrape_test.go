@@ -0,0 +1,138 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestScrape(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+
+	bodies := []string{`
+# HELP temp the temperature
+# TYPE temp gauge
+temp{room="kitchen"} 20
+# TYPE reqs counter
+reqs_total{code="200"} 100 1234
+# TYPE lat histogram
+lat_bucket{le="1"} 1
+lat_bucket{le="2"} 2
+lat_bucket{le="+Inf"} 2
+lat_sum 2
+lat_count 2
+untyped_thing 7
+`, `
+# TYPE temp gauge
+temp{room="kitchen"} 21
+# TYPE reqs counter
+reqs_total{code="200"} 130
+# TYPE lat histogram
+lat_bucket{le="1"} 3
+lat_bucket{le="2"} 5
+lat_bucket{le="+Inf"} 6
+lat_sum 12
+lat_count 6
+`}
+
+	var scrapes int
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(bodies[scrapes]))
+			scrapes++
+		}))
+	defer srv.Close()
+
+	l, err := NewScrape(ScrapeOptions{
+		Targets: []string{srv.URL},
+		Name:    `prom.{{ .Name }}{{ .Tags }}`,
+	})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.NoError(t, l.scrape(ctx, w, srv.URL, now))
+	assert.NoError(t, l.scrape(ctx, w, srv.URL, now.Add(10*time.Second)))
+
+	type result struct {
+		obs      int64
+		min, max float64
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{rec.Observations, rec.Min, rec.Max}
+			return true
+		})
+
+	assert.DeepEqual(t, got, map[string]result{
+		"prom.temp;room=kitchen":   {2, 20, 21},
+		"prom.reqs_total;code=200": {1, 3, 3},
+		"prom.lat":                 {4, 0.5, 2},
+		"prom.lat_sum":             {1, 1, 1},
+		"prom.lat_count":           {1, 0.4, 0.4},
+		"prom.untyped_thing":       {1, 7, 7},
+	})
+}
+
+func TestScrapeTooLarge(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			line := []byte("# " + strings.Repeat("x", 1021) + "\n")
+			for i := 0; i <= maxScrapeBody/len(line); i++ {
+				if _, err := w.Write(line); err != nil {
+					return
+				}
+			}
+		}))
+	defer srv.Close()
+
+	l, err := NewScrape(ScrapeOptions{Targets: []string{srv.URL}})
+	assert.NoError(t, err)
+	assert.Error(t, l.scrape(ctx, w, srv.URL, time.Now()))
+}
+
+func TestParseExposition(t *testing.T) {
+	e, err := parseExposition(strings.NewReader(`
+# TYPE a summary
+a{quantile="0.5",path="/a \"b\"\\c",} 1.5e3
+a_sum +Inf
+b 1 100
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, e.typeOf("a"), "summary")
+	assert.Equal(t, e.typeOf("a_sum"), "summary")
+	assert.Equal(t, e.typeOf("b"), "untyped")
+	assert.Equal(t, len(e.samples), 3)
+	assert.DeepEqual(t, e.samples[0], expoSample{
+		name: "a",
+		labels: []label{
+			{name: "quantile", value: "0.5"},
+			{name: "path", value: `/a "b"\c`},
+		},
+		value: 1500,
+	})
+
+	for _, bad := range []string{
+		"a",
+		"a{b=\"c\" 1",
+		"a{b=c} 1",
+		"a 1 2 3",
+		"a x",
+	} {
+		_, err := parseExposition(strings.NewReader(bad))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,5 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package sketch provides a listener for pre-aggregated distributions, so
+// that clients can send the sketches they computed rather than every value.
+package sketch
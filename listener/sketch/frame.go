@@ -0,0 +1,42 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package sketch
+
+import (
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/data/frame"
+	"github.com/zeebo/errs"
+)
+
+// AppendFrame appends the metric and the marshaled record to the buffer in
+// the form accepted by batch requests: the uvarint length of the metric, the
+// metric, the uvarint length of the record and the record.
+func AppendFrame(buf []byte, metric string, rec data.Record) ([]byte, error) {
+	return frame.Append(buf, metric, rec)
+}
+
+// batchFrame is a metric and marshaled record parsed out of a batch request.
+type batchFrame struct {
+	metric string
+	record []byte
+}
+
+// parseFrames parses the frames out of a batch request.
+func parseFrames(buf []byte) (frames []batchFrame, err error) {
+	for len(buf) > 0 {
+		var metric string
+		var record []byte
+		metric, record, buf, err = frame.Next(buf)
+		if err != nil {
+			return nil, err
+		}
+		if len(metric) == 0 {
+			return nil, errs.New("empty metric name")
+		}
+		frames = append(frames, batchFrame{
+			metric: metric,
+			record: record,
+		})
+	}
+	return frames, nil
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package sketch
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// maxBody is the largest decompressed body accepted.
+const maxBody = 64 << 20
+
+// Options controls the behavior of the sketch listener.
+type Options struct {
+	// Path is the http path that accepts sketches. Defaults to "/sketch".
+	Path string
+}
+
+// Listener implements the listener.Listener for pre-aggregated sketches sent
+// over http. Requests are POSTs to the path and come in three forms:
+//
+//	?metric=name           the body is a marshaled data.Record
+//	?metric=name&kind=kind the body is a marshaled distribution of the kind
+//	no metric              the body is a batch of frames from AppendFrame
+//
+// Every sketch is merged into the current record for the metric, so the
+// distributions must be the same kind as the writer's, and support merging.
+// The frames of a batch are only merged if every one of them can be.
+type Listener struct {
+	address string
+	opts    Options
+}
+
+// New returns a Listener that when Run will listen on the provided address.
+func New(address string, opts Options) *Listener {
+	if opts.Path == "" {
+		opts.Path = "/sketch"
+	}
+
+	return &Listener{
+		address: address,
+		opts:    opts,
+	}
+}
+
+// Run listens on the address and merges all of the sketches into the writer.
+func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
+	mux := http.NewServeMux()
+	mux.Handle(l.opts.Path, l.handler(ctx, w))
+	return listener.RunHTTP(ctx, l.address, mux)
+}
+
+// handler returns an http.Handler that merges the sketches from requests into
+// the writer.
+func (l *Listener) handler(ctx context.Context, w *data.Writer) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := l.handleRequest(ctx, w, req)
+		if err != nil {
+			external.Errorw("invalid sketch request",
+				"peer", req.RemoteAddr,
+				"err", err.Error(),
+			)
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleRequest reads the body of the request and merges it into the writer.
+func (l *Listener) handleRequest(ctx context.Context, w *data.Writer,
+	req *http.Request) (err error) {
+
+	var body io.Reader = req.Body
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return errs.New("unsupported content encoding: %q", encoding)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(body, maxBody+1))
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if len(buf) > maxBody {
+		return errs.New("request body too large")
+	}
+
+	query := req.URL.Query()
+	metric, kind := query.Get("metric"), query.Get("kind")
+	switch {
+	case metric != "" && kind != "":
+		return w.AddDist(ctx, metric, kind, buf)
+
+	case metric != "":
+		return w.Queue(ctx, metric, 0, 0, buf, nil)
+
+	case kind != "":
+		return errs.New("kind specified without a metric")
+	}
+
+	frames, err := parseFrames(buf)
+	if err != nil {
+		return err
+	}
+
+	// check every frame before merging any of them, so that a rejected batch
+	// can be retried without counting the earlier frames twice.
+	recs := make([]data.Record, len(frames))
+	for i, frame := range frames {
+		if err := checkRecord(w, frame.metric, frame.record,
+			&recs[i]); err != nil {
+
+			return errs.New("%s: %v", frame.metric, err)
+		}
+	}
+	for i, frame := range frames {
+		if err := w.AddRecord(ctx, frame.metric, recs[i]); err != nil {
+			return errs.New("%s: %v", frame.metric, err)
+		}
+	}
+	return nil
+}
+
+// checkRecord unmarshals the record into rec and checks that its
+// distribution can be merged into the writer's distribution for the metric.
+func checkRecord(w *data.Writer, metric string, buf []byte,
+	rec *data.Record) error {
+
+	if err := rec.Unmarshal(buf); err != nil {
+		return errs.Wrap(err)
+	}
+
+	params := w.Params(metric)
+	if rec.Kind != params.Kind() {
+		return errs.New("can not merge %q record into %q distribution",
+			rec.Kind, params.Kind())
+	}
+	d, err := params.Unmarshal(rec.Distribution)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if _, ok := d.(dist.Merger); !ok {
+		return errs.New("%s distributions can not be merged", d.Kind())
+	}
+	return nil
+}
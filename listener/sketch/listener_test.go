@@ -0,0 +1,117 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package sketch
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestListener(t *testing.T) {
+	ctx := context.Background()
+	params := tdigest.Params{Compression: 5}
+	w := data.NewWriter(params)
+	l := New("", Options{})
+
+	sketch := func(vals ...float64) []byte {
+		d, err := params.New()
+		assert.NoError(t, err)
+		for _, val := range vals {
+			d.Observe(val)
+		}
+		return d.Marshal(nil)
+	}
+
+	post := func(query string, body []byte) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/sketch"+query,
+			bytes.NewReader(body))
+		l.handler(ctx, w).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	rec, err := (&data.Record{
+		Observations: 3,
+		Min:          1,
+		Max:          3,
+		MaxId:        []byte("id"),
+		Kind:         "tdigest",
+		Distribution: sketch(1, 2, 3),
+	}).Marshal()
+	assert.NoError(t, err)
+
+	assert.Equal(t, post("?metric=a", rec), http.StatusNoContent)
+	assert.Equal(t, post("?metric=a&kind=tdigest", sketch(4, 5)),
+		http.StatusNoContent)
+
+	batch, err := AppendFrame(nil, "b", data.Record{
+		Observations: 1,
+		Min:          7,
+		Max:          7,
+		Kind:         "tdigest",
+		Distribution: sketch(7),
+	})
+	assert.NoError(t, err)
+	batch, err = AppendFrame(batch, "c", data.Record{
+		Observations: 1,
+		Min:          8,
+		Max:          8,
+		Kind:         "tdigest",
+		Distribution: sketch(8),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, post("", batch), http.StatusNoContent)
+
+	assert.Equal(t, post("?metric=a&kind=other", nil), http.StatusBadRequest)
+	assert.Equal(t, post("?kind=tdigest", nil), http.StatusBadRequest)
+	assert.Equal(t, post("", batch[:len(batch)-1]), http.StatusBadRequest)
+
+	// a batch with a bad frame merges none of its frames.
+	bad, err := AppendFrame(nil, "d", data.Record{
+		Observations: 1,
+		Min:          9,
+		Max:          9,
+		Kind:         "tdigest",
+		Distribution: sketch(9),
+	})
+	assert.NoError(t, err)
+	bad, err = AppendFrame(bad, "e", data.Record{
+		Observations: 1,
+		Kind:         "other",
+		Distribution: sketch(9),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, post("", bad), http.StatusBadRequest)
+
+	type result struct {
+		obs      int64
+		min, max float64
+		max_id   string
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx, func(ctx context.Context, name string,
+		rec data.Record) bool {
+
+		d, err := params.Unmarshal(rec.Distribution)
+		assert.NoError(t, err)
+		assert.Equal(t, d.Len(), rec.Observations)
+
+		got[name] = result{rec.Observations, rec.Min, rec.Max,
+			string(rec.MaxId)}
+		return true
+	})
+
+	assert.DeepEqual(t, got, map[string]result{
+		"a": {5, 1, 5, ""},
+		"b": {1, 7, 7, ""},
+		"c": {1, 8, 8, ""},
+	})
+}
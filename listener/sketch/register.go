@@ -0,0 +1,27 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package sketch
+
+import (
+	"context"
+
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/listener"
+	"github.com/vivint/rothko/registry"
+)
+
+func init() {
+	registry.RegisterListener("sketch", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			address := a.I("address").String()
+			path := a.I("path").String()
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			return New(address, Options{
+				Path: path,
+			}), nil
+		}))
+}
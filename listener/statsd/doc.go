@@ -0,0 +1,4 @@
+// Copyright (C) 2018. See AUTHORS.
+
+// package statsd provides a listener for the statsd wire protocol.
+package statsd
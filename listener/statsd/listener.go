@@ -0,0 +1,226 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/external"
+	"github.com/vivint/rothko/listener"
+	"github.com/zeebo/errs"
+)
+
+// Options controls the behavior of the statsd listener.
+type Options struct {
+	// Interval controls how often summed counters are added as an
+	// observation. Defaults to 10 seconds.
+	Interval time.Duration
+
+	// Bufsize is the size of the buffer used to read packets. Defaults to
+	// 65535.
+	Bufsize int
+
+	// GaugeTTL is how long the last value of a gauge is remembered for
+	// relative adjustments after it was last set. Defaults to 10 minutes.
+	GaugeTTL time.Duration
+}
+
+// maxWeight bounds the weight of a sampled value, so that tiny sample rates
+// do not overflow. It corresponds to a sample rate of one in a million.
+const maxWeight = 1000000
+
+// gauge is the last value of a gauge and when it was set.
+type gauge struct {
+	value float64
+	seen  time.Time
+}
+
+// Listener implements the listener.Listener for the statsd wire protocol.
+type Listener struct {
+	address string
+	opts    Options
+
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]gauge
+}
+
+// New returns a Listener that when Run will listen on the provided address.
+func New(address string, opts Options) *Listener {
+	if opts.Interval == 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Bufsize == 0 {
+		opts.Bufsize = 65535
+	}
+	if opts.GaugeTTL == 0 {
+		opts.GaugeTTL = 10 * time.Minute
+	}
+
+	return &Listener{
+		address: address,
+		opts:    opts,
+
+		counters: make(map[string]float64),
+		gauges:   make(map[string]gauge),
+	}
+}
+
+// Run listens on the address and writes all of the metrics to the writer.
+func (l *Listener) Run(ctx context.Context, w *data.Writer) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// flush the counters periodically until the listener stops.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(l.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				l.flush(ctx, w)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err = listener.RunUDP(ctx, l.address, l.opts.Bufsize,
+		func(packet []byte, addr net.Addr) {
+			l.handlePacket(ctx, w, packet, addr)
+		})
+
+	cancel()
+	wg.Wait()
+	l.flush(ctx, w)
+	return err
+}
+
+// handlePacket adds every line in the packet to the writer.
+func (l *Listener) handlePacket(ctx context.Context, w *data.Writer,
+	packet []byte, addr net.Addr) {
+
+	for len(packet) > 0 {
+		var line []byte
+		if index := bytes.IndexByte(packet, '\n'); index >= 0 {
+			line, packet = packet[:index], packet[index+1:]
+		} else {
+			line, packet = packet, nil
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		err := l.handleLine(ctx, w, line)
+		if err != nil {
+			external.Errorw("invalid statsd line",
+				"line", string(line),
+				"peer", addr.String(),
+				"err", err.Error(),
+			)
+		}
+	}
+}
+
+// handleLine adds the statsd data in the line to the writer.
+func (l *Listener) handleLine(ctx context.Context, w *data.Writer,
+	line []byte) (err error) {
+
+	fields := bytes.Split(line, []byte{'|'})
+	if len(fields) < 2 {
+		return errs.New("bad number of fields: %d", len(fields))
+	}
+
+	colon := bytes.LastIndexByte(fields[0], ':')
+	if colon <= 0 {
+		return errs.New("missing metric name")
+	}
+	metric := string(fields[0][:colon])
+	raw_value := fields[0][colon+1:]
+
+	value, err := strconv.ParseFloat(string(raw_value), 64)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	// look for a sample rate in the optional fields. we ignore any other
+	// extensions, like tags.
+	rate := 1.0
+	for _, field := range fields[2:] {
+		if len(field) == 0 || field[0] != '@' {
+			continue
+		}
+		rate, err = strconv.ParseFloat(string(field[1:]), 64)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		if rate <= 0 || rate > 1 {
+			return errs.New("invalid sample rate: %v", rate)
+		}
+	}
+
+	switch kind := string(fields[1]); kind {
+	case "ms", "h":
+		weight := int64(maxWeight)
+		if rate >= 1.0/maxWeight {
+			weight = int64(math.Round(1 / rate))
+		}
+		w.AddWeighted(ctx, metric, value, weight, nil)
+
+	case "g":
+		// a leading sign means the gauge is being adjusted relative to the
+		// last value we saw for it.
+		l.mu.Lock()
+		if raw_value[0] == '+' || raw_value[0] == '-' {
+			value += l.gauges[metric].value
+		}
+		l.gauges[metric] = gauge{value: value, seen: time.Now()}
+		l.mu.Unlock()
+
+		w.Add(ctx, metric, value, nil)
+
+	case "c":
+		l.mu.Lock()
+		l.counters[metric] += value / rate
+		l.mu.Unlock()
+
+	default:
+		return errs.New("unknown metric type: %q", kind)
+	}
+
+	return nil
+}
+
+// flush adds the summed counters to the writer and resets them. It also
+// forgets any gauges that have not been set within the gauge ttl.
+func (l *Listener) flush(ctx context.Context, w *data.Writer) {
+	now := time.Now()
+
+	l.mu.Lock()
+	counters := l.counters
+	l.counters = make(map[string]float64, len(counters))
+	for metric, g := range l.gauges {
+		if now.Sub(g.seen) >= l.opts.GaugeTTL {
+			delete(l.gauges, metric)
+		}
+	}
+	l.mu.Unlock()
+
+	for metric, value := range counters {
+		w.Add(ctx, metric, value, nil)
+	}
+}
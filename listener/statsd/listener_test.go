@@ -0,0 +1,104 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package statsd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestListener(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l := New("", Options{})
+
+	packet := []byte(strings.Join([]string{
+		"test.timer:10|ms",
+		"test.sampled:10|ms|@0.1",
+		"test.tiny:10|ms|@1e-300",
+		"test.hist:3|h|#tag:value",
+		"test.gauge:5|g",
+		"test.gauge:-2|g",
+		"test.counter:1|c",
+		"test.counter:2|c|@0.5",
+		"test.bad:1|x",
+	}, "\n"))
+
+	l.handlePacket(ctx, w, packet, &net.UDPAddr{})
+	l.flush(ctx, w)
+
+	type result struct {
+		obs      int64
+		min, max float64
+	}
+
+	got := make(map[string]result)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = result{rec.Observations, rec.Min, rec.Max}
+			return true
+		})
+
+	assert.DeepEqual(t, got, map[string]result{
+		"test.timer":   {1, 10, 10},
+		"test.sampled": {10, 10, 10},
+		"test.tiny":    {maxWeight, 10, 10},
+		"test.hist":    {1, 3, 3},
+		"test.gauge":   {2, 3, 5},
+		"test.counter": {1, 5, 5},
+	})
+}
+
+func TestListenerGaugeTTL(t *testing.T) {
+	ctx := context.Background()
+	w := data.NewWriter(fakeParams{})
+	l := New("", Options{GaugeTTL: time.Minute})
+
+	l.handlePacket(ctx, w, []byte("test.old:5|g\ntest.new:5|g"),
+		&net.UDPAddr{})
+
+	// pretend the old gauge was set long enough ago to be forgotten.
+	old := l.gauges["test.old"]
+	old.seen = old.seen.Add(-time.Hour)
+	l.gauges["test.old"] = old
+	l.flush(ctx, w)
+
+	l.handlePacket(ctx, w, []byte("test.old:+1|g\ntest.new:+1|g"),
+		&net.UDPAddr{})
+
+	got := make(map[string]float64)
+	w.Capture(ctx,
+		func(ctx context.Context, name string, rec data.Record) bool {
+			got[name] = rec.Max
+			return true
+		})
+
+	assert.DeepEqual(t, got, map[string]float64{
+		"test.old": 5,
+		"test.new": 6,
+	})
+}
+
+//
+// fakes. only required functions stubbed out. sorry if you break this
+// accidentally!
+//
+
+type fakeParams struct{ dist.Params }
+
+func (fakeParams) Kind() string            { return "fake" }
+func (fakeParams) New() (dist.Dist, error) { return fakeDist{}, nil }
+
+type fakeDist struct{ dist.Dist }
+
+func (fakeDist) Kind() string                              { return "fake" }
+func (fakeDist) Observe(val float64)                       {}
+func (fakeDist) ObserveWeighted(val float64, weight int64) {}
+func (fakeDist) Marshal(x []byte) []byte                   { return x }
@@ -0,0 +1,47 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package statsd
+
+import (
+	"context"
+	"time"
+
+	"github.com/vivint/rothko/internal/typeassert"
+	"github.com/vivint/rothko/listener"
+	"github.com/vivint/rothko/registry"
+	"github.com/zeebo/errs"
+)
+
+func init() {
+	registry.RegisterListener("statsd", registry.ListenerMakerFunc(
+		func(ctx context.Context, config interface{}) (listener.Listener, error) {
+			a := typeassert.A(config)
+			address := a.I("address").String()
+			interval := a.I("interval").String()
+			gauge_ttl := a.I("gauge_ttl").String()
+			bufsize := a.I("bufsize").Int64()
+			if err := a.Err(); err != nil {
+				return nil, err
+			}
+
+			opts := Options{
+				Bufsize: int(bufsize),
+			}
+			if interval != "" {
+				dur, err := time.ParseDuration(interval)
+				if err != nil {
+					return nil, errs.Wrap(err)
+				}
+				opts.Interval = dur
+			}
+			if gauge_ttl != "" {
+				dur, err := time.ParseDuration(gauge_ttl)
+				if err != nil {
+					return nil, errs.Wrap(err)
+				}
+				opts.GaugeTTL = dur
+			}
+
+			return New(address, opts), nil
+		}))
+}
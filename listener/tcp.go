@@ -0,0 +1,87 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/vivint/rothko/external"
+	"github.com/zeebo/errs"
+)
+
+// RunTCP listens on the address and calls the handler with every connection
+// until the context is canceled. It is a helper for listeners that receive
+// data over tcp. The connections are closed when the context is canceled.
+func RunTCP(ctx context.Context, address string,
+	handle func(ctx context.Context, conn net.Conn) error) (err error) {
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	defer lis.Close()
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- handleListener(ctx, lis, handle)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		lis.Close()
+		wg.Wait()
+		return nil
+	}
+}
+
+// handleListener accepts connections from the listener and spawns handlers
+// for them.
+func handleListener(ctx context.Context, lis net.Listener,
+	handle func(ctx context.Context, conn net.Conn) error) (err error) {
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			// close the connection if the context is canceled so that the
+			// handler stops reading from it.
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+
+			err := handle(ctx, conn)
+			if err != nil && ctx.Err() == nil {
+				external.Errorw("connection error",
+					"peer", conn.RemoteAddr().String(),
+					"err", err.Error(),
+				)
+			}
+		}()
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package listener
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/zeebo/errs"
+)
+
+// RunUDP listens for datagrams on the address and calls the handler with
+// every one until the context is canceled. It is a helper for listeners that
+// receive data over udp. The packet passed to the handler is only valid
+// until it returns.
+func RunUDP(ctx context.Context, address string, bufsize int,
+	handle func(packet []byte, addr net.Addr)) (err error) {
+
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		buf := make([]byte, bufsize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				errs <- err
+				return
+			}
+			handle(buf[:n], addr)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		conn.Close()
+		wg.Wait()
+		return nil
+	}
+}
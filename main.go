@@ -8,8 +8,17 @@ import (
 
 	"github.com/urfave/cli"
 	_ "github.com/vivint/rothko/database/files"
+	_ "github.com/vivint/rothko/dist/ddsketch"
+	_ "github.com/vivint/rothko/dist/exact"
+	_ "github.com/vivint/rothko/dist/hdr"
+	_ "github.com/vivint/rothko/dist/llhist"
 	_ "github.com/vivint/rothko/dist/tdigest"
 	_ "github.com/vivint/rothko/listener/graphite"
+	_ "github.com/vivint/rothko/listener/influx"
+	_ "github.com/vivint/rothko/listener/otlp"
+	_ "github.com/vivint/rothko/listener/prometheus"
+	_ "github.com/vivint/rothko/listener/sketch"
+	_ "github.com/vivint/rothko/listener/statsd"
 	"github.com/zeebo/errs"
 )
 
@@ -6,17 +6,16 @@ import (
 	"context"
 
 	"github.com/vivint/rothko/data"
-	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/dist"
 )
 
-// TODO(jeff): don't merge into tdigest, merge into a flat weighted buffer
-// sampled from the distribution's cdf.
-
 // MergeOptions are the arguments passed to Merge.
 type MergeOptions struct {
-	// Params are the parameters for the output distribution the merged record
-	// should have.
-	Params tdigest.Params
+	// Params are the parameters for the output distribution. Records that are
+	// all the same kind are merged natively with dist.Merger, in to a new
+	// distribution from the Params if it is that kind. Otherwise, they are
+	// resampled in to a new distribution from the Params.
+	Params dist.Params
 
 	// Records are the set of records to merge.
 	Records []data.Record
@@ -43,34 +42,29 @@ func Merge(ctx context.Context, opts MergeOptions) (
 		}
 	}
 
-	// merge the observations
+	// merge the observations and the sums. if any record is missing the
+	// sums, the output can't have correct ones either, so leave them out.
+	has_sums := true
 	for _, r := range opts.Records {
 		out.Observations += r.Observations
+		out.Sum += r.Sum
+		out.SumSquares += r.SumSquares
+		has_sums = has_sums && r.HasSums()
+	}
+	if !has_sums {
+		out.Sum, out.SumSquares = 0, 0
 	}
+	out.ExactSums = has_sums
 
 	// merge the distributions
-	dist, err := opts.Params.New()
-	if err != nil {
-		return out, err
-	}
-	unwrapped, ok := dist.(*tdigest.Wrapper)
-	if !ok {
-		return out, Error.New("programmer error: params did not make t-digest")
-	}
-	res := newResampler(unwrapped.Underlying())
-	for _, r := range opts.Records {
-		err := res.Sample(ctx, r)
-		if err != nil {
-			return out, err
-		}
-	}
-	out.Distribution, out.Kind, err = res.Finish(ctx)
+	out.Distribution, out.Kind, err = mergeDists(ctx, opts.Params, opts.Records)
 	if err != nil {
 		return out, err
 	}
 
 	// merge the max and min values
 	out.Min, out.Max = opts.Records[0].Min, opts.Records[0].Max
+	out.MinId, out.MaxId = opts.Records[0].MinId, opts.Records[0].MaxId
 	for _, r := range opts.Records[1:] {
 		if r.Min < out.Min {
 			out.Min = r.Min
@@ -82,6 +76,13 @@ func Merge(ctx context.Context, opts MergeOptions) (
 		}
 	}
 
+	// merge the exemplars
+	exemplars := make([][]data.Exemplar, 0, len(opts.Records))
+	for _, r := range opts.Records {
+		exemplars = append(exemplars, r.Exemplars)
+	}
+	out.Exemplars = data.MergeExemplars(data.MaxExemplars, exemplars...)
+
 	// merge how many we've merged
 	for _, r := range opts.Records {
 		// back compat: there may have been records without the merged field.
@@ -0,0 +1,74 @@
+// Copyright (C) 2018. See AUTHORS.
+
+package merge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vivint/rothko/data"
+	"github.com/vivint/rothko/data/load"
+	"github.com/vivint/rothko/dist"
+	"github.com/vivint/rothko/dist/ddsketch"
+	"github.com/vivint/rothko/dist/llhist"
+	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/internal/assert"
+)
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+
+	record := func(params dist.Params, vals ...float64) data.Record {
+		d, err := params.New()
+		assert.NoError(t, err)
+		for _, val := range vals {
+			d.Observe(val)
+		}
+		return data.Record{
+			StartTime:    1,
+			EndTime:      2,
+			Observations: int64(len(vals)),
+			Min:          vals[0],
+			Max:          vals[len(vals)-1],
+			Distribution: d.Marshal(nil),
+			Kind:         d.Kind(),
+		}
+	}
+
+	merge := func(params dist.Params, recs ...data.Record) (
+		data.Record, dist.Dist) {
+
+		out, err := Merge(ctx, MergeOptions{Params: params, Records: recs})
+		assert.NoError(t, err)
+		d, err := load.Load(ctx, out)
+		assert.NoError(t, err)
+		return out, d
+	}
+
+	td := tdigest.Params{Compression: 5}
+	dd := ddsketch.Params{RelativeAccuracy: 0.01}
+	ll := llhist.Params{}
+
+	// records of the same kind are merged natively
+	out, d := merge(td, record(dd, 1, 2, 3), record(dd, 4, 5))
+	assert.Equal(t, out.Kind, "ddsketch")
+	assert.Equal(t, d.Len(), int64(5))
+	assert.Equal(t, d.Query(0), 1.0)
+	assert.Equal(t, d.Query(1), 5.0)
+
+	out, d = merge(td, record(td, 1, 2, 3), record(td, 4, 5))
+	assert.Equal(t, out.Kind, "tdigest")
+	assert.Equal(t, d.Len(), int64(5))
+
+	// records of different kinds are resampled in to the params
+	out, d = merge(td, record(dd, 1, 2, 3), record(ll, 4, 5))
+	assert.Equal(t, out.Kind, "tdigest")
+	assert.Equal(t, out.Observations, int64(5))
+	assert.Equal(t, d.Len(), int64(5))
+
+	// records of the same kind that can't be merged are also resampled
+	other := ddsketch.Params{RelativeAccuracy: 0.05}
+	out, d = merge(td, record(dd, 1, 2, 3), record(other, 4, 5))
+	assert.Equal(t, out.Kind, "tdigest")
+	assert.Equal(t, d.Len(), int64(5))
+}
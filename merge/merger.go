@@ -5,11 +5,12 @@ package merge
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/vivint/rothko/data"
 	"github.com/vivint/rothko/data/load"
-	"github.com/vivint/rothko/dist/tdigest"
+	"github.com/vivint/rothko/dist"
 	"github.com/vivint/rothko/draw"
 	"github.com/zeebo/errs"
 	"github.com/zeebo/float16"
@@ -35,7 +36,7 @@ type MergerOptions struct {
 	Samples  int
 	Now      int64
 	Duration time.Duration
-	Params   tdigest.Params
+	Params   dist.Params
 }
 
 // Merger allows iterative pushing of records in and constructs a series of
@@ -222,6 +223,8 @@ func (m *Merger) emit(ctx context.Context, start, end int64,
 		W:      int(end - start + 1),
 		Data:   make([]float64, 0, m.opts.Samples+1),
 		ObsSec: obs_sec,
+		MinId:  string(out.MinId),
+		MaxId:  string(out.MaxId),
 	}
 	f64_samples := float64(m.opts.Samples)
 	for i := float64(0); i <= f64_samples; i++ {
@@ -233,6 +236,12 @@ func (m *Merger) emit(ctx context.Context, start, end int64,
 		col.Data = append(col.Data, val)
 	}
 
+	if out.HasSums() {
+		col.Mean, col.Stddev, col.Exact = out.Mean(), out.Stddev(), true
+	} else {
+		col.Mean, col.Stddev = sampleStats(col.Data)
+	}
+
 	m.columns = append(m.columns, col)
 	return nil
 }
@@ -249,3 +258,22 @@ func intsEq(a, b []int) bool {
 	}
 	return true
 }
+
+// sampleStats estimates the mean and standard deviation of a distribution
+// from evenly spaced quantile samples of it.
+func sampleStats(samples []float64) (mean, stddev float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	var sum, sum_squares float64
+	for _, val := range samples {
+		sum += val
+		sum_squares += val * val
+	}
+	n := float64(len(samples))
+	mean = sum / n
+	if variance := sum_squares/n - mean*mean; variance > 0 {
+		stddev = math.Sqrt(variance)
+	}
+	return mean, stddev
+}
@@ -6,43 +6,118 @@ import (
 	"context"
 
 	"github.com/vivint/rothko/data"
-	"github.com/zeebo/tdigest"
+	"github.com/vivint/rothko/data/load"
+	"github.com/vivint/rothko/dist"
 )
 
-//
-// a resampler is used to merge a bunch of unknown distribution kinds into a
-// single t-digest, since t-digest seems to be the best so far. hopefully
-// it will always be the case that we can support this operation with whatever
-// distributions we have in the future.
-//
+// resampleCount is the most quantiles sampled out of a distribution that can
+// not be merged natively or have its bins read.
+const resampleCount = 1000
 
-// TODO(jeff): this can probably use some refactoring to use the Dist
-// abstraction and data/dists/... packages. At the same time, these details
-// are going to be specific to the concrete distributions, so perhaps it's ok.
+// binned is implemented by distributions that keep counts of values in bins.
+type binned interface {
+	Bins(fn func(value float64, count uint64) bool)
+}
 
-type resampler struct {
-	dig *tdigest.TDigest
+// holder is implemented by distributions that may hold another distribution,
+// like exact values that were promoted to a sketch.
+type holder interface {
+	Sketch() dist.Dist
 }
 
-func newResampler(dig *tdigest.TDigest) *resampler {
-	return &resampler{
-		dig: dig,
+// mergeDists merges the distributions of the records, returning the merged
+// distribution in byte form and its kind. If the records all have the same
+// kind, they are merged natively if possible. Otherwise, they are resampled
+// in to a new distribution made by the params.
+func mergeDists(ctx context.Context, params dist.Params, recs []data.Record) (
+	[]byte, string, error) {
+
+	dists := make([]dist.Dist, 0, len(recs))
+	same := true
+	for _, r := range recs {
+		d, err := load.Load(ctx, r)
+		if err != nil {
+			return nil, "", err
+		}
+		dists = append(dists, d)
+		same = same && r.Kind == recs[0].Kind
 	}
-}
 
-func (res *resampler) Sample(ctx context.Context, r data.Record) error {
-	switch r.Kind {
-	case "tdigest":
-		other, err := tdigest.FromBytes(r.Distribution)
+	if same {
+		out, ok, err := mergeNative(ctx, params, recs[0], dists)
 		if err != nil {
-			return err
+			return nil, "", err
+		}
+		if ok {
+			return out.Marshal(nil), out.Kind(), nil
 		}
-		return res.dig.Merge(other)
 	}
 
-	return Error.New("unknown distribution kind: %v", r.Kind)
+	out, err := params.New()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, d := range dists {
+		resample(out, d)
+	}
+	return out.Marshal(nil), out.Kind(), nil
 }
 
-func (res *resampler) Finish(ctx context.Context) ([]byte, string, error) {
-	return res.dig.Marshal(nil), "tdigest", nil
+// mergeNative merges the distributions, which are all the same kind as the
+// first record, with their Merge method. If the params make that kind, they
+// are merged in to a new distribution from the params, and otherwise in to a
+// fresh copy of the first record's distribution. It returns false if they
+// can not be merged.
+func mergeNative(ctx context.Context, params dist.Params, first data.Record,
+	dists []dist.Dist) (dist.Dist, bool, error) {
+
+	var out dist.Dist
+	var err error
+	if params.Kind() == first.Kind {
+		out, err = params.New()
+	} else {
+		out, err = load.Load(ctx, first)
+		dists = dists[1:]
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	m, ok := out.(dist.Merger)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, d := range dists {
+		if err := m.Merge(d); err != nil {
+			return nil, false, nil
+		}
+	}
+	return out, true, nil
+}
+
+// resample adds the observations of the distribution to out. It uses the bins
+// of the distribution if it has them, and otherwise evenly spaced quantiles,
+// each weighted by its share of the observations.
+func resample(out, d dist.Dist) {
+	if h, ok := d.(holder); ok && h.Sketch() != nil {
+		d = h.Sketch()
+	}
+
+	if b, ok := d.(binned); ok {
+		b.Bins(func(value float64, count uint64) bool {
+			out.ObserveWeighted(value, int64(count))
+			return true
+		})
+		return
+	}
+
+	total := d.Len()
+	samples := int64(resampleCount)
+	if total < samples {
+		samples = total
+	}
+	for i := int64(0); i < samples; i++ {
+		weight := total*(i+1)/samples - total*i/samples
+		out.ObserveWeighted(d.Query((float64(i)+0.5)/float64(samples)), weight)
+	}
 }
@@ -12,7 +12,9 @@ import (
 	"github.com/zeebo/errs"
 )
 
-// Registry keeps track of a set of Makers by name.
+// Registry keeps track of a set of Makers by name. Makers are called without
+// holding any locks, so they may use the Registry to make the entities they
+// are composed of.
 type Registry struct {
 	mu sync.Mutex
 
@@ -38,9 +40,8 @@ func (r *Registry) NewListener(ctx context.Context, name string,
 	config interface{}) (listener.Listener, error) {
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	maker, ok := r.listeners[name]
+	r.mu.Unlock()
 	if !ok {
 		return nil, errs.New("no registration for: %q", name)
 	}
@@ -65,9 +66,8 @@ func (r *Registry) NewDatabase(ctx context.Context, name string,
 	config interface{}) (database.DB, error) {
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	maker, ok := r.databases[name]
+	r.mu.Unlock()
 	if !ok {
 		return nil, errs.New("no registration for: %q", name)
 	}
@@ -93,9 +93,8 @@ func (r *Registry) NewDistribution(ctx context.Context, name string,
 	config interface{}) (dist.Params, error) {
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	maker, ok := r.distributions[name]
+	r.mu.Unlock()
 	if !ok {
 		return nil, errs.New("no registration for: %q", name)
 	}
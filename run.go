@@ -10,11 +10,14 @@ import (
 	"net"
 	"net/http"
 	"plugin"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/urfave/cli"
 	"github.com/vivint/rothko/api"
+	"github.com/vivint/rothko/api/query"
+	"github.com/vivint/rothko/checkpoint"
 	"github.com/vivint/rothko/config"
 	"github.com/vivint/rothko/data"
 	"github.com/vivint/rothko/dump"
@@ -22,6 +25,7 @@ import (
 	"github.com/vivint/rothko/internal/junk"
 	"github.com/vivint/rothko/internal/tgzfs"
 	"github.com/vivint/rothko/internal/tmplfs"
+	"github.com/vivint/rothko/listener"
 	"github.com/vivint/rothko/registry"
 	"github.com/vivint/rothko/ui"
 	"github.com/zeebo/errs"
@@ -107,8 +111,69 @@ func run(ctx context.Context, conf *config.Config) (started bool, err error) {
 		return false, errs.Wrap(err)
 	}
 
+	// make sure distributions can be created before any values arrive.
+	if _, err := params.New(); err != nil {
+		return false, errs.Wrap(err)
+	}
+
+	// create the distribution params for any rules
+	var rules []data.Rule
+	for _, rule := range conf.DistRules {
+		external.Infow("creating distribution rule",
+			"pattern", rule.Pattern,
+			"kind", rule.Kind,
+			"config", rule.Config,
+		)
+		params, err := registry.NewDistribution(ctx, rule.Kind, rule.Config)
+		if err != nil {
+			return false, errs.Wrap(err)
+		}
+		if _, err := params.New(); err != nil {
+			return false, errs.Wrap(err)
+		}
+
+		pattern := strings.ToLower(rule.Pattern)
+		rules = append(rules, data.Rule{
+			Match: func(metric string) bool {
+				return query.Match(pattern, metric)
+			},
+			Params: params,
+		})
+	}
+
 	// create the writer
-	w := data.NewWriter(params)
+	policy, err := data.ParseLimitPolicy(conf.Limits.Policy)
+	if err != nil {
+		return false, errs.Wrap(err)
+	}
+	w := data.NewWriterWithOptions(params, data.WriterOptions{
+		Windows: conf.Main.Windows,
+		Rules:   rules,
+		Limits: data.Limits{
+			Global:      conf.Limits.Global,
+			PerPrefix:   conf.Limits.PerPrefix,
+			PrefixDepth: conf.Limits.PrefixDepth,
+			Policy:      policy,
+			Overflow:    conf.Limits.Overflow,
+		},
+	})
+
+	// restore the records from the checkpoint before any listeners start
+	// adding values to the writer.
+	var checkpointer *checkpoint.Checkpointer
+	if conf.Main.Checkpoint != "" {
+		checkpointer = checkpoint.New(checkpoint.Options{
+			Path:     conf.Main.Checkpoint,
+			Interval: conf.Main.CheckpointInterval,
+		})
+
+		external.Infow("replaying checkpoint",
+			"path", conf.Main.Checkpoint,
+		)
+		if err := checkpointer.Replay(ctx, w, db); err != nil {
+			return false, errs.Wrap(err)
+		}
+	}
 
 	// create the dumper
 	dumper := dump.New(dump.Options{
@@ -129,12 +194,20 @@ func run(ctx context.Context, conf *config.Config) (started bool, err error) {
 		}
 		static = tmplfs.New(fs)
 	}
+	late, err := listener.ParseLatePolicy(conf.API.Late)
+	if err != nil {
+		return false, errs.Wrap(err)
+	}
 	srv := &http.Server{
 		Addr: conf.API.Address,
 		Handler: api.New(db, static, api.Options{
-			Origin:   conf.API.Origin,
-			Username: conf.API.Security.Username,
-			Password: conf.API.Security.Password,
+			Origin:     conf.API.Origin,
+			Username:   conf.API.Security.Username,
+			Password:   conf.API.Security.Password,
+			Writer:     w,
+			Late:       late,
+			Tolerance:  conf.API.Tolerance,
+			LateSuffix: conf.API.LateSuffix,
 		}),
 	}
 
@@ -166,6 +239,16 @@ func run(ctx context.Context, conf *config.Config) (started bool, err error) {
 		return dumper.Run(ctx, w)
 	})
 
+	// queue the worker that periodically checkpoints the writer
+	if checkpointer != nil {
+		launcher.Queue(func(ctx context.Context) error {
+			external.Infow("starting checkpointer",
+				"path", conf.Main.Checkpoint,
+			)
+			return checkpointer.Run(ctx, w)
+		})
+	}
+
 	// queue the api server
 	launcher.Queue(func(ctx context.Context) error {
 		external.Infow("starting api",